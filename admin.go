@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// schemaBackfills lists the fields schema-drift can check, each with the
+// aggregation expression used to backfill documents that lack it. Only fields
+// every current write stores belong here: an omitempty field such as stock
+// is legitimately absent from new books, so its absence is not drift.
+var schemaBackfills = map[string]interface{}{
+	"createdAt": bson.M{"$toDate": "$_id"}, // the ObjectID embeds the insert time
+}
+
+const (
+	defaultDriftSample = 1000
+	maxDriftExamples   = 10
+)
+
+type fieldDrift struct {
+	Missing  int      `json:"missing"`
+	Examples []string `json:"examples"`
+}
+
+func (h *BookHandler) registerAdminRoutes(admin *gin.RouterGroup) {
+	admin.Use(requireAdmin)
+	admin.GET("/schema-drift", h.getSchemaDrift)                // Report missing fields in a sample (?fix=true backfills them)
+	admin.POST("/bestsellers/refresh", h.refreshBestsellersNow) // Recompute the bestseller ranking now
+	admin.POST("/normalize-authors", h.normalizeAuthors)        // Clean up stored author names (?dry_run=true)
+}
+
+// Sample the collection for documents missing the tracked fields, and with
+// ?fix=true backfill them on every document, not just the sample
+func (h *BookHandler) getSchemaDrift(c *gin.Context) {
+	sample := defaultDriftSample
+	if raw := c.Query("sample"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sample must be a positive integer"})
+			return
+		}
+		sample = n
+	}
+	fix := false
+	if raw := c.Query("fix"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fix must be a boolean"})
+			return
+		}
+		fix = b
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	projection := bson.M{"_id": 1}
+	for _, field := range h.config.SchemaDriftFields {
+		projection[field] = 1
+	}
+	cursor, err := h.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sample", Value: bson.M{"size": sample}}},
+		{{Key: "$project", Value: projection}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sampling books"})
+		return
+	}
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sampling books"})
+		return
+	}
+
+	report := make(map[string]*fieldDrift, len(h.config.SchemaDriftFields))
+	for _, field := range h.config.SchemaDriftFields {
+		drift := &fieldDrift{Examples: []string{}}
+		for _, doc := range docs {
+			if _, ok := doc[field]; ok {
+				continue
+			}
+			drift.Missing++
+			if len(drift.Examples) < maxDriftExamples {
+				drift.Examples = append(drift.Examples, idString(doc["_id"]))
+			}
+		}
+		report[field] = drift
+	}
+
+	if !fix {
+		c.JSON(http.StatusOK, gin.H{"sampled": len(docs), "fields": report})
+		return
+	}
+
+	// The report above describes the collection before the backfill.
+	fixed := make(map[string]int64, len(h.config.SchemaDriftFields))
+	for _, field := range h.config.SchemaDriftFields {
+		result, err := h.collection.UpdateMany(
+			ctx,
+			bson.M{field: bson.M{"$exists": false}},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{field: schemaBackfills[field]}}}},
+		)
+		if err != nil {
+			log.Printf("schema drift: backfilling %s: %v", field, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error backfilling " + field, "fixed": fixed})
+			return
+		}
+		fixed[field] = result.ModifiedCount
+		if result.ModifiedCount > 0 {
+			h.bumpGeneration(ctx)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sampled": len(docs), "fields": report, "fixed": fixed})
+}
+
+// normalizeBatchSize bounds how many updates one BulkWrite carries.
+const normalizeBatchSize = 500
+
+type authorChange struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Apply normalizeAuthor to every stored book, or with ?dry_run=true only
+// report what would change
+func (h *BookHandler) normalizeAuthors(c *gin.Context) {
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be a boolean"})
+			return
+		}
+		dryRun = b
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	cursor, err := h.collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"author": 1}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading books"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var (
+		scanned, changed, modified, overCap int64
+		examples                            = []authorChange{}
+		batch                               []mongo.WriteModel
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := h.collection.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false))
+		if result != nil && result.ModifiedCount > 0 {
+			modified += result.ModifiedCount
+			h.bumpGeneration(ctx)
+		}
+		batch = batch[:0]
+		return err
+	}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID     interface{} `bson:"_id"`
+			Author string      `bson:"author"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading books"})
+			return
+		}
+		scanned++
+		normalized := normalizeAuthor(doc.Author)
+		if normalized == doc.Author {
+			continue
+		}
+		changed++
+		if len(examples) < maxDriftExamples {
+			examples = append(examples, authorChange{ID: idString(doc.ID), From: doc.Author, To: normalized})
+		}
+		if dryRun {
+			continue
+		}
+		// Only rewrite the name if nobody changed it since it was read.
+		filter := bson.M{"_id": doc.ID, "author": doc.Author}
+		update := bson.M{"$set": bson.M{"author": normalized}}
+		if h.config.MaxBooksPerAuthor > 0 {
+			// Merging variants can take an author over the cap, so each
+			// move is checked on its own, as a PUT changing the author is.
+			var result *mongo.UpdateResult
+			err := h.writeUnderAuthorCap(ctx, doc.Author, normalized, func(ctx context.Context) error {
+				var err error
+				result, err = h.collection.UpdateOne(ctx, filter, update)
+				return err
+			})
+			if errors.Is(err, errAuthorCapReached) {
+				overCap++
+				continue
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating authors", "modified": modified})
+				return
+			}
+			modified += result.ModifiedCount
+			continue
+		}
+		batch = append(batch, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+		if len(batch) == normalizeBatchSize {
+			if err := flush(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating authors", "modified": modified})
+				return
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading books", "modified": modified})
+		return
+	}
+	if err := flush(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating authors", "modified": modified})
+		return
+	}
+	if h.config.MaxBooksPerAuthor > 0 && modified > 0 {
+		h.bumpGeneration(ctx) // flush bumps it for the uncapped batches
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scanned":  scanned,
+		"changed":  changed,
+		"modified": modified,
+		"overCap":  overCap,
+		"dryRun":   dryRun,
+		"examples": examples,
+	})
+}
+
+// idString renders a decoded _id for reports, whatever its BSON type.
+func idString(id interface{}) string {
+	if oid, ok := id.(interface{ Hex() string }); ok {
+		return oid.Hex()
+	}
+	return fmt.Sprint(id)
+}
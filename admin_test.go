@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSchemaDriftDetectAndBackfill(t *testing.T) {
+	h, router := newTestHandler(t)
+	// A book written by the API today, and one stored before createdAt was.
+	expectStatus(t, serve(router, http.MethodPost, "/books", Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99}), http.StatusCreated)
+	legacy := insertBooks(t, h, Book{Title: "Emma", Author: "Jane Austen", Price: 4.5})[0]
+
+	type driftReport struct {
+		Sampled int                   `json:"sampled"`
+		Fields  map[string]fieldDrift `json:"fields"`
+	}
+	var report driftReport
+	w := serve(router, http.MethodGet, "/admin/schema-drift", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	decodeBody(t, w, &report)
+	drift := report.Fields["createdAt"]
+	if report.Sampled != 2 || drift.Missing != 1 || len(drift.Examples) != 1 || drift.Examples[0] != legacy.Hex() {
+		t.Fatalf("report = %+v, want only %s missing createdAt", report, legacy.Hex())
+	}
+	if got := findBook(t, h, legacy); got.CreatedAt != nil {
+		t.Fatalf("report without ?fix=true backfilled createdAt = %v", got.CreatedAt)
+	}
+
+	var backfill struct {
+		Fixed map[string]int64 `json:"fixed"`
+	}
+	w = serve(router, http.MethodGet, "/admin/schema-drift?fix=true", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	decodeBody(t, w, &backfill)
+	if backfill.Fixed["createdAt"] != 1 {
+		t.Fatalf("fixed = %v, want 1 createdAt", backfill.Fixed)
+	}
+	if got := findBook(t, h, legacy); got.CreatedAt == nil || !got.CreatedAt.Equal(legacy.Timestamp()) {
+		t.Fatalf("backfilled createdAt = %v, want %v", got.CreatedAt, legacy.Timestamp())
+	}
+
+	report = driftReport{}
+	w = serve(router, http.MethodGet, "/admin/schema-drift", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	decodeBody(t, w, &report)
+	if missing := report.Fields["createdAt"].Missing; missing != 0 {
+		t.Fatalf("missing after backfill = %d, want 0", missing)
+	}
+}
+
+func TestNormalizeAuthorsCleansUpMessyNames(t *testing.T) {
+	h, router := newTestHandler(t)
+	messy := []string{"  frank   herbert ", "JANE AUSTEN", "Ursula K. Le Guin", "gabriel garcía márquez", "mary o'brien-smith"}
+	clean := []string{"Frank Herbert", "Jane Austen", "Ursula K. Le Guin", "Gabriel García Márquez", "Mary O'Brien-Smith"}
+	books := make([]Book, len(messy))
+	for i, author := range messy {
+		books[i] = Book{Title: fmt.Sprint("Book ", i), Author: author}
+	}
+	ids := insertBooks(t, h, books...)
+
+	type report struct {
+		Scanned  int  `json:"scanned"`
+		Changed  int  `json:"changed"`
+		Modified int  `json:"modified"`
+		DryRun   bool `json:"dryRun"`
+	}
+
+	w := serve(router, http.MethodPost, "/admin/normalize-authors?dry_run=true", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var dry report
+	decodeBody(t, w, &dry)
+	if want := (report{Scanned: 5, Changed: 4, DryRun: true}); dry != want {
+		t.Fatalf("dry run = %+v, want %+v", dry, want)
+	}
+	if got := findBook(t, h, ids[0]).Author; got != messy[0] {
+		t.Fatalf("dry run rewrote %q to %q", messy[0], got)
+	}
+
+	w = serve(router, http.MethodPost, "/admin/normalize-authors", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var run report
+	decodeBody(t, w, &run)
+	if want := (report{Scanned: 5, Changed: 4, Modified: 4}); run != want {
+		t.Fatalf("run = %+v, want %+v", run, want)
+	}
+	for i, id := range ids {
+		if got := findBook(t, h, id).Author; got != clean[i] {
+			t.Errorf("author %q became %q, want %q", messy[i], got, clean[i])
+		}
+	}
+
+	expectStatus(t, serve(router, http.MethodPost, "/admin/normalize-authors", nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxAuditEntries bounds the size of one reference price sheet.
+const maxAuditEntries = 1000
+
+type priceAuditEntry struct {
+	ISBN     string  `json:"isbn"`
+	Expected float64 `json:"expected"`
+}
+
+type priceMismatch struct {
+	ISBN     string             `json:"isbn"`
+	ID       primitive.ObjectID `json:"id"`
+	Expected float64            `json:"expected"`
+	Actual   float64            `json:"actual"`
+	Fixed    bool               `json:"fixed"`
+}
+
+type priceAuditReport struct {
+	Matched    []string        `json:"matched"`
+	Mismatched []priceMismatch `json:"mismatched"`
+	NotFound   []string        `json:"notFound"`
+}
+
+// Compare book prices against a reference sheet keyed by ISBN
+func (h *BookHandler) auditPrices(c *gin.Context) {
+	fix := false
+	if raw := c.Query("fix"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fix must be a boolean"})
+			return
+		}
+		fix = b
+	}
+	if fix && rejectNonAdmin(c) {
+		return
+	}
+
+	var entries []priceAuditEntry
+	if err := c.ShouldBindJSON(&entries); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(entries) == 0 || len(entries) > maxAuditEntries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expected between 1 and %d entries", maxAuditEntries)})
+		return
+	}
+	var errs []FieldError
+	isbns := make([]string, len(entries))
+	for i := range entries {
+		entries[i].ISBN = normalizeISBN(entries[i].ISBN)
+		isbns[i] = entries[i].ISBN
+		if !validISBN(entries[i].ISBN) {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("[%d].isbn", i), Message: "must be a 10 or 13 digit ISBN"})
+		}
+		if entries[i].Expected < 0 {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("[%d].expected", i), Message: "must not be negative"})
+		}
+	}
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": errs})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	books, err := h.findBooks(ctx, bson.M{"isbn": bson.M{"$in": isbns}, "deletedAt": notDeleted})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	byISBN := make(map[string]Book, len(books))
+	for _, book := range books {
+		byISBN[book.ISBN] = book
+	}
+
+	report := priceAuditReport{Matched: []string{}, Mismatched: []priceMismatch{}, NotFound: []string{}}
+	for _, entry := range entries {
+		book, ok := byISBN[entry.ISBN]
+		switch {
+		case !ok:
+			report.NotFound = append(report.NotFound, entry.ISBN)
+		case samePrice(book.Price, entry.Expected):
+			report.Matched = append(report.Matched, entry.ISBN)
+		default:
+			report.Mismatched = append(report.Mismatched, priceMismatch{
+				ISBN: entry.ISBN, ID: book.ID, Expected: entry.Expected, Actual: book.Price,
+			})
+		}
+	}
+
+	if fix {
+		fixed := false
+		var fixErr error
+		for i, m := range report.Mismatched {
+			// Only overwrite the price the audit actually saw; a book changed
+			// since then is left alone and reported unfixed.
+			var book Book
+			err := h.collection.FindOneAndUpdate(
+				ctx,
+				bson.M{"_id": m.ID, "deletedAt": notDeleted, "price": m.Actual},
+				bson.M{"$set": bson.M{"price": m.Expected}},
+				options.FindOneAndUpdate().SetReturnDocument(options.After),
+			).Decode(&book)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+			if err != nil {
+				fixErr = err
+				break
+			}
+			report.Mismatched[i].Fixed = true
+			h.recordPriceChange(ctx, m.ID, m.Actual, m.Expected)
+			previous := book
+			previous.Price = m.Actual
+			h.webhooks.notify(newWebhookEvent(eventBookUpdated, book.ID, &previous, &book, h.now().UTC()))
+			fixed = true
+		}
+		if fixed {
+			h.bumpGeneration(ctx)
+		}
+		if fixErr != nil {
+			log.Printf("audit prices: fixing: %v", fixErr)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fixing prices", "report": report})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// samePrice compares prices to the cent.
+func samePrice(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAuditPricesMatchMismatchMissing(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.WebhookURLs = []string{server.URL} })
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", Price: 9.99},
+		Book{Title: "Emma", Author: "Jane Austen", ISBN: "9780141439587", Price: 10},
+	)
+	sheet := []priceAuditEntry{
+		{ISBN: "978-0-441-17271-9", Expected: 9.99},
+		{ISBN: "9780141439587", Expected: 12},
+		{ISBN: "9780000000000", Expected: 5},
+		// A second price for Emma: by the time it is applied, the price the
+		// audit saw is gone, so it must not be reported fixed.
+		{ISBN: "9780141439587", Expected: 13},
+	}
+
+	// Reporting is open to anyone; fixing is not.
+	expectStatus(t, serve(router, http.MethodPost, "/books/audit-prices", sheet), http.StatusOK)
+	expectStatus(t, serve(router, http.MethodPost, "/books/audit-prices?fix=true", sheet), http.StatusUnauthorized)
+	if got := findBook(t, h, ids[1]).Price; got != 10 {
+		t.Fatalf("Emma price = %v after unauthorized fix, want 10", got)
+	}
+
+	w := serve(router, http.MethodPost, "/books/audit-prices?fix=true", sheet, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var report priceAuditReport
+	decodeBody(t, w, &report)
+
+	if want := []string{"9780441172719"}; !reflect.DeepEqual(report.Matched, want) {
+		t.Errorf("matched = %v, want %v", report.Matched, want)
+	}
+	if want := []string{"9780000000000"}; !reflect.DeepEqual(report.NotFound, want) {
+		t.Errorf("notFound = %v, want %v", report.NotFound, want)
+	}
+	want := []priceMismatch{
+		{ISBN: "9780141439587", ID: ids[1], Expected: 12, Actual: 10, Fixed: true},
+		{ISBN: "9780141439587", ID: ids[1], Expected: 13, Actual: 10, Fixed: false},
+	}
+	if !reflect.DeepEqual(report.Mismatched, want) {
+		t.Errorf("mismatched = %+v, want %+v", report.Mismatched, want)
+	}
+	if got := findBook(t, h, ids[1]).Price; got != 12 {
+		t.Errorf("Emma price = %v, want 12", got)
+	}
+
+	var history []PriceChange
+	cursor, err := h.prices.Find(testContext(t), bson.M{"bookId": ids[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cursor.All(testContext(t), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Price != 12 {
+		t.Errorf("price history = %+v, want only the applied change to 12", history)
+	}
+
+	// One book.updated for the one fixed book.
+	receiver.waitFor(t, 1)
+	time.Sleep(50 * time.Millisecond)
+	if got := receiver.waitFor(t, 1); len(got) != 1 {
+		t.Errorf("fix sent %d webhooks, want 1", len(got))
+	}
+}
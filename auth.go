@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roleKey is the gin context key holding the caller's role.
+const roleKey = "role"
+
+const (
+	rolePublic = "public"
+	roleAdmin  = "admin"
+)
+
+// adminOnlyFields are the book fields only admins may see or write.
+var adminOnlyFields = map[string]bool{"cost": true, "supplier": true}
+
+// authenticate resolves the caller's role from an "Authorization: Bearer"
+// header. No header leaves the caller public; a token other than ADMIN_TOKEN
+// is rejected with 401.
+func (h *BookHandler) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(roleKey, rolePublic)
+
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || h.config.AdminToken == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(h.config.AdminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		c.Set(roleKey, roleAdmin)
+		c.Next()
+	}
+}
+
+// requireAdmin rejects callers without the admin role: 401 when they sent
+// no credentials, 403 otherwise.
+func requireAdmin(c *gin.Context) {
+	if !rejectNonAdmin(c) {
+		c.Next()
+	}
+}
+
+// rejectNonAdmin answers as requireAdmin does and reports true unless the
+// caller is an admin. Handlers use it to gate admin-only options of routes
+// open to everyone.
+func rejectNonAdmin(c *gin.Context) bool {
+	if isAdmin(c) {
+		return false
+	}
+	if c.GetHeader("Authorization") == "" {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin role required"})
+	return true
+}
+
+func isAdmin(c *gin.Context) bool {
+	return c.GetString(roleKey) == roleAdmin
+}
+
+// redactBook clears the admin-only fields unless the caller is an admin.
+func redactBook(c *gin.Context, book *Book) {
+	if !isAdmin(c) {
+		book.Cost, book.Supplier = 0, ""
+	}
+}
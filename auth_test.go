@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// secretBook has both admin-only fields set.
+var secretBook = Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99, Stock: 3, Cost: 4.2, Supplier: "Chilton"}
+
+func TestAdminOnlyFieldsAreRedactedForThePublic(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, secretBook)
+	if _, err := h.sales.InsertOne(testContext(t), Sale{BookID: ids[0], Quantity: 30, SoldAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []struct {
+		name, method, path string
+		body               interface{}
+		book               func(map[string]interface{}) map[string]interface{}
+	}{
+		{"book", http.MethodGet, "/books/" + ids[0].Hex(), nil, nil},
+		{"review", http.MethodPost, "/books/" + ids[0].Hex() + "/reviews", Review{Reviewer: "ann", Rating: 5}, nil},
+	}
+	for _, route := range routes {
+		for _, admin := range []bool{false, true} {
+			var headers []string
+			if admin {
+				headers = asAdmin
+			}
+			w := serve(router, route.method, route.path, route.body, headers...)
+			if w.Code >= 300 {
+				t.Fatalf("%s: status = %d: %s", route.name, w.Code, w.Body)
+			}
+			var book map[string]interface{}
+			decodeBody(t, w, &book)
+			expectAdminFields(t, route.name, book, admin)
+		}
+	}
+}
+
+func TestRestockSuggestionsAreRedactedForThePublic(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, secretBook)
+	if _, err := h.sales.InsertOne(testContext(t), Sale{BookID: ids[0], Quantity: 30, SoldAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, admin := range []bool{false, true} {
+		var headers []string
+		if admin {
+			headers = asAdmin
+		}
+		w := serve(router, http.MethodGet, "/books/restock-suggestions", nil, headers...)
+		expectStatus(t, w, http.StatusOK)
+		var suggestions []struct {
+			Book map[string]interface{} `json:"book"`
+		}
+		decodeBody(t, w, &suggestions)
+		if len(suggestions) != 1 {
+			t.Fatalf("got %d suggestions, want 1", len(suggestions))
+		}
+		expectAdminFields(t, "restock-suggestions", suggestions[0].Book, admin)
+	}
+}
+
+func TestBackupRequiresAdmin(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h, secretBook)
+
+	expectStatus(t, serve(router, http.MethodGet, "/books/backup", nil), http.StatusUnauthorized)
+	expectStatus(t, serve(router, http.MethodGet, "/books/backup", nil, "Authorization", "Bearer wrong"), http.StatusUnauthorized)
+
+	w := serve(router, http.MethodGet, "/books/backup", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	if dumped := readDump(t, w.Body.Bytes()); !strings.Contains(dumped, `"supplier":"Chilton"`) {
+		t.Fatalf("admin backup lacks the supplier: %s", dumped)
+	}
+}
+
+// expectAdminFields checks that cost and supplier are present for admins and
+// absent for everyone else.
+func expectAdminFields(t *testing.T, name string, book map[string]interface{}, admin bool) {
+	t.Helper()
+	for _, field := range []string{"cost", "supplier"} {
+		if _, ok := book[field]; ok != admin {
+			t.Errorf("%s (admin=%v): %s present = %v", name, admin, field, ok)
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+}
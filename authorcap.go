@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errAuthorCapReached = errors.New("author has reached the book limit")
+
+// insertUnderAuthorCap inserts the book only while its author stays within
+// MaxBooksPerAuthor. Every insert for an author first writes the same lock
+// document in meta, so two concurrent inserts conflict and one of them
+// aborts instead of both passing the count.
+func (h *BookHandler) insertUnderAuthorCap(ctx context.Context, book Book) (*mongo.InsertOneResult, error) {
+	var result *mongo.InsertOneResult
+	err := h.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		room, err := h.authorRoom(sc, book.Author)
+		if err != nil {
+			return err
+		}
+		if room <= 0 {
+			return errAuthorCapReached
+		}
+
+		result, err = h.collection.InsertOne(sc, book)
+		return err
+	})
+	return result, err
+}
+
+// writeUnderAuthorCap runs write, which moves a book from one author to
+// another, only while the new author stays within MaxBooksPerAuthor. It takes
+// the same lock document as insertUnderAuthorCap, so a move racing an insert
+// for that author conflicts rather than both passing the count. Writes that
+// keep the author, or with no cap configured, run as they are.
+func (h *BookHandler) writeUnderAuthorCap(ctx context.Context, from, to string, write func(context.Context) error) error {
+	if h.config.MaxBooksPerAuthor <= 0 || from == to {
+		return write(ctx)
+	}
+	return h.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		room, err := h.authorRoom(sc, to)
+		if err != nil {
+			return err
+		}
+		if room <= 0 {
+			return errAuthorCapReached
+		}
+		return write(sc)
+	})
+}
+
+// authorCapReply answers an update refused by writeUnderAuthorCap, and
+// reports whether it did.
+func (h *BookHandler) authorCapReply(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, errAuthorCapReached):
+		c.JSON(http.StatusConflict, gin.H{"error": "Author has reached the book limit", "limit": h.config.MaxBooksPerAuthor})
+	case isWriteConflict(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "Another book by this author is being written, try again"})
+	default:
+		return false
+	}
+	return true
+}
+
+// admitUnderAuthorCap reports, for each of books in order, whether its author
+// still has room for it under MaxBooksPerAuthor, counting the books admitted
+// before it. It takes the same locks as insertUnderAuthorCap, so it must run
+// in the transaction that then inserts the admitted books.
+func (h *BookHandler) admitUnderAuthorCap(sc mongo.SessionContext, books []Book) ([]bool, error) {
+	admit := make([]bool, len(books))
+	rooms := make(map[string]int64)
+	for i, book := range books {
+		room, ok := rooms[book.Author]
+		if !ok {
+			var err error
+			if room, err = h.authorRoom(sc, book.Author); err != nil {
+				return nil, err
+			}
+		}
+		if room > 0 {
+			admit[i] = true
+			room--
+		}
+		rooms[book.Author] = room
+	}
+	return admit, nil
+}
+
+// authorRoom takes the author's lock document in meta and returns how many
+// more books the author may have.
+func (h *BookHandler) authorRoom(sc mongo.SessionContext, author string) (int64, error) {
+	_, err := h.meta.UpdateOne(
+		sc,
+		bson.M{"_id": "author:" + author},
+		bson.M{"$inc": bson.M{"inserts": int64(1)}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := h.collection.CountDocuments(sc, bson.M{"author": author, "deletedAt": notDeleted})
+	if err != nil {
+		return 0, err
+	}
+	return int64(h.config.MaxBooksPerAuthor) - count, nil
+}
+
+// isWriteConflict reports whether a transaction lost a race with a
+// concurrent one and may simply be retried.
+func isWriteConflict(err error) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorLabel("TransientTransactionError")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAuthorCapRejectsTheBookPastTheLimit(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.MaxBooksPerAuthor = 2 })
+	requireReplicaSet(t)
+
+	for _, title := range []string{"Dune", "Dune Messiah"} {
+		w := serve(router, http.MethodPost, "/books", `{"title": "`+title+`", "author": "Frank Herbert"}`)
+		expectStatus(t, w, http.StatusCreated)
+	}
+	w := serve(router, http.MethodPost, "/books", `{"title": "Children of Dune", "author": "Frank Herbert"}`)
+	expectStatus(t, w, http.StatusConflict)
+	var body struct {
+		Limit int `json:"limit"`
+	}
+	decodeBody(t, w, &body)
+	if body.Limit != 2 {
+		t.Fatalf("limit = %d, want 2", body.Limit)
+	}
+
+	// The cap is per author.
+	w = serve(router, http.MethodPost, "/books", `{"title": "Emma", "author": "Jane Austen"}`)
+	expectStatus(t, w, http.StatusCreated)
+
+	if n, err := h.collection.CountDocuments(testContext(t), bson.M{"author": "Frank Herbert"}); err != nil || n != 2 {
+		t.Fatalf("Frank Herbert has %d books (%v), want 2", n, err)
+	}
+}
+
+func TestAuthorCapHoldsUnderConcurrentInserts(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.MaxBooksPerAuthor = 2 })
+	requireReplicaSet(t)
+
+	const requests = 10
+	var wg sync.WaitGroup
+	codes := make([]int, requests)
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			book := fmt.Sprintf(`{"title": "Book %d", "author": "Frank Herbert"}`, i)
+			codes[i] = serve(router, http.MethodPost, "/books", book).Code
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for i, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Fatalf("request %d: status = %d", i, code)
+		}
+	}
+	n, err := h.collection.CountDocuments(testContext(t), bson.M{"author": "Frank Herbert"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n > 2 || int64(created) != n {
+		t.Fatalf("%d created, %d stored, want at most 2 of each", created, n)
+	}
+}
+
+func TestAuthorCapAppliesWhenAnUpdateChangesTheAuthor(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.MaxBooksPerAuthor = 2 })
+	requireReplicaSet(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert"},
+		Book{Title: "Dune Messiah", Author: "Frank Herbert"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+		Book{Title: "Children of Dune", Author: "frank  herbert"},
+	)
+	emma := "/books/" + ids[2].Hex()
+
+	expectStatus(t, serve(router, http.MethodPut, emma, `{"title": "Emma", "author": "Frank Herbert"}`), http.StatusConflict)
+	expectStatus(t, serve(router, http.MethodPatch, emma, `{"author": "Frank Herbert"}`), http.StatusConflict)
+	// Writes keeping the author are not counted against it.
+	expectStatus(t, serve(router, http.MethodPatch, "/books/"+ids[0].Hex(), `{"price": 9.99}`), http.StatusOK)
+
+	// Normalizing the variant would make a third Frank Herbert book.
+	w := serve(router, http.MethodPost, "/admin/normalize-authors", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var report struct {
+		Modified int `json:"modified"`
+		OverCap  int `json:"overCap"`
+	}
+	decodeBody(t, w, &report)
+	if report.Modified != 0 || report.OverCap != 1 {
+		t.Fatalf("normalize = %+v, want the one variant left over the cap", report)
+	}
+
+	if n, err := h.collection.CountDocuments(testContext(t), bson.M{"author": "Frank Herbert"}); err != nil || n != 2 {
+		t.Fatalf("Frank Herbert has %d books (%v), want 2", n, err)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// restoreBatchSize bounds how many decoded books are held before writing.
+const restoreBatchSize = 500
+
+// Stream the whole catalog as gzipped NDJSON, one book per line
+func (h *BookHandler) backupBooks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	cursor, err := h.collection.Find(ctx, bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	filename := fmt.Sprintf("books-%s.ndjson", h.now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Encoding", "gzip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// The gzip trailer is only written once every book is, so a failure
+	// part way through drops the connection rather than ending the stream:
+	// a truncated dump must not look like a complete one.
+	gz := gzip.NewWriter(c.Writer)
+	enc := json.NewEncoder(gz)
+	for cursor.Next(ctx) {
+		var book Book
+		if err := cursor.Decode(&book); err != nil {
+			log.Printf("backup: decode: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+		if err := enc.Encode(book); err != nil {
+			log.Printf("backup: write: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("backup: cursor: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	if err := gz.Close(); err != nil {
+		log.Printf("backup: write: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// Restore modes accepted by restoreBooks via ?mode=.
+const (
+	restoreInsert  = "insert"  // insert every record, failing on existing IDs
+	restoreReplace = "replace" // wipe the collection and insert, in one transaction
+	restoreMerge   = "merge"   // upsert records by ID, inserting those without one
+)
+
+// maxRestoreErrors caps how many failure messages a restore reports back.
+const maxRestoreErrors = 100
+
+type restoreResult struct {
+	Inserted int64    `json:"inserted"`
+	Updated  int64    `json:"updated"`
+	Failed   int64    `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+func (r *restoreResult) fail(format string, args ...interface{}) {
+	r.Failed++
+	if len(r.Errors) < maxRestoreErrors {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// dumpError reports a record that could not be decoded at all.
+type dumpError struct {
+	record int
+	err    error
+}
+
+func (e dumpError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.record, e.err)
+}
+
+// Restore books from a gzipped NDJSON dump produced by backupBooks
+func (h *BookHandler) restoreBooks(c *gin.Context) {
+	mode := c.DefaultQuery("mode", restoreInsert)
+	if mode != restoreInsert && mode != restoreReplace && mode != restoreMerge {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be insert, replace or merge"})
+		return
+	}
+
+	body, err := backupReader(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var result restoreResult
+	if mode == restoreReplace {
+		err = h.inTransaction(ctx, func(sc mongo.SessionContext) error {
+			if _, err := h.collection.DeleteMany(sc, bson.M{}); err != nil {
+				return err
+			}
+			return h.restore(sc, body, mode, &result)
+		})
+		if err != nil {
+			// Nothing was committed, so report the counts as they now stand.
+			result.Inserted, result.Updated = 0, 0
+		}
+	} else {
+		err = h.restore(ctx, body, mode, &result)
+	}
+
+	if (err == nil && mode == restoreReplace) || result.Inserted+result.Updated > 0 {
+		h.bumpGeneration(ctx)
+	}
+
+	var decodeErr dumpError
+	switch {
+	case errors.As(err, &decodeErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": decodeErr.Error(), "result": result})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error restoring books", "result": result})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// restore decodes records from r and writes them in batches. Records failing
+// validation are skipped and counted; write errors are counted too, except in
+// replace mode where they abort the surrounding transaction.
+func (h *BookHandler) restore(ctx context.Context, r io.Reader, mode string, result *restoreResult) error {
+	dec := json.NewDecoder(r)
+	models := make([]mongo.WriteModel, 0, restoreBatchSize)
+
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		res, err := h.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		models = models[:0]
+		if res != nil {
+			result.Inserted += res.InsertedCount + res.UpsertedCount
+			result.Updated += res.MatchedCount
+		}
+		var bwe mongo.BulkWriteException
+		if mode != restoreReplace && errors.As(err, &bwe) && bwe.WriteConcernError == nil {
+			for _, we := range bwe.WriteErrors {
+				result.fail("write: %s", we.Message)
+			}
+			return nil
+		}
+		return err
+	}
+
+	for record := 1; ; record++ {
+		var book Book
+		if err := dec.Decode(&book); err == io.EOF {
+			break
+		} else if err != nil {
+			return dumpError{record: record, err: err}
+		}
+		if errs := book.validate(); len(errs) > 0 {
+			result.fail("record %d: %s %s", record, errs[0].Field, errs[0].Message)
+			continue
+		}
+
+		if mode == restoreMerge && !book.ID.IsZero() {
+			models = append(models, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": book.ID}).
+				SetReplacement(book).
+				SetUpsert(true))
+		} else {
+			models = append(models, mongo.NewInsertOneModel().SetDocument(book))
+		}
+
+		if len(models) == restoreBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// backupReader unwraps a gzipped dump, passing plain NDJSON through untouched.
+func backupReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == io.EOF || (err == nil && (magic[0] != 0x1f || magic[1] != 0x8b)) {
+		return br, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return gzip.NewReader(br)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dump encodes books as a gzipped NDJSON dump, as backupBooks writes them.
+func dump(t *testing.T, books ...Book) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, book := range books {
+		if err := enc.Encode(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreRequiresAdmin(t *testing.T) {
+	_, router := newTestHandler(t)
+
+	w := serve(router, http.MethodPost, "/books/restore?mode=replace", dump(t, Book{Title: "Dune", Author: "Frank Herbert"}))
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestRestoreIntoEmptyCollection(t *testing.T) {
+	h, router := newTestHandler(t)
+	books := []Book{
+		{ID: primitive.NewObjectID(), Title: "Dune", Author: "Frank Herbert", Price: 9.99},
+		{ID: primitive.NewObjectID(), Title: "Emma", Author: "Jane Austen", Price: 4.5},
+	}
+
+	w := serve(router, http.MethodPost, "/books/restore", dump(t, books...), asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var result restoreResult
+	decodeBody(t, w, &result)
+	if result.Inserted != 2 || result.Failed != 0 {
+		t.Fatalf("result = %+v, want 2 inserted", result)
+	}
+	for _, book := range books {
+		if got := findBook(t, h, book.ID); got.Title != book.Title {
+			t.Fatalf("restored title = %q, want %q", got.Title, book.Title)
+		}
+	}
+}
+
+func TestRestoreIntoNonEmptyCollection(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99})
+	dumped := dump(t,
+		Book{ID: ids[0], Title: "Dune Messiah", Author: "Frank Herbert", Price: 8},
+		Book{ID: primitive.NewObjectID(), Title: "Emma", Author: "Jane Austen", Price: 4.5},
+	)
+
+	// Insert mode keeps the existing book and reports its ID clash.
+	w := serve(router, http.MethodPost, "/books/restore?mode=insert", dumped, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var result restoreResult
+	decodeBody(t, w, &result)
+	if result.Inserted != 1 || result.Failed != 1 {
+		t.Fatalf("insert result = %+v, want 1 inserted and 1 failed", result)
+	}
+	if got := findBook(t, h, ids[0]); got.Title != "Dune" {
+		t.Fatalf("title after insert mode = %q, want the original", got.Title)
+	}
+
+	// Merge mode overwrites it.
+	w = serve(router, http.MethodPost, "/books/restore?mode=merge", dumped, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	if got := findBook(t, h, ids[0]); got.Title != "Dune Messiah" {
+		t.Fatalf("title after merge mode = %q, want the dumped one", got.Title)
+	}
+	if n, _ := h.collection.CountDocuments(testContext(t), bson.M{}); n != 2 {
+		t.Fatalf("count = %d, want 2", n)
+	}
+}
+
+func TestRestoreReplaceWipesTheCollection(t *testing.T) {
+	requireReplicaSet(t)
+	h, router := newTestHandler(t)
+	insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert"}, Book{Title: "Emma", Author: "Jane Austen"})
+
+	w := serve(router, http.MethodPost, "/books/restore?mode=replace", dump(t, Book{Title: "Ulysses", Author: "James Joyce"}), asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	books, err := h.findBooks(testContext(t), bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 1 || books[0].Title != "Ulysses" {
+		t.Fatalf("books = %v, want only Ulysses", titles(books))
+	}
+}
+
+// readDump gunzips a dump written by backupBooks.
+func readDump(t *testing.T, body []byte) string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dumped, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(dumped)
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	source, sourceRouter := newTestHandler(t)
+	published := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	ids := insertBooks(t, source,
+		Book{
+			Title: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", Price: 9.99,
+			PublishedAt: &published, CreatedAt: &created, Stock: 7, ReorderPoint: 2,
+			Location: &Location{Shelf: "SF", Row: 3}, Tags: []string{"classic", "space"},
+			Genre: "sci-fi", WordCount: 188000, Cost: 4.2, Supplier: "Chilton",
+			StockByLocation: map[string]int{"north": 4},
+			Translations:    map[string]string{"fr": "Dune"},
+			Reviews:         []Review{{Reviewer: "ann", Rating: 5, Comment: "Spice", CreatedAt: created}},
+			AverageRating:   5, ReviewCount: 1,
+		},
+		Book{Title: "Emma", Author: "Jane Austen", Price: 4.5, OutOfPrint: true},
+	)
+
+	w := serve(sourceRouter, http.MethodGet, "/books/backup", nil, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+
+	target, targetRouter := newTestHandler(t)
+	w = serve(targetRouter, http.MethodPost, "/books/restore", w.Body.Bytes(), asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var result restoreResult
+	decodeBody(t, w, &result)
+	if result.Inserted != 2 || result.Failed != 0 {
+		t.Fatalf("result = %+v, want 2 inserted", result)
+	}
+
+	for _, id := range ids {
+		want, got := findBook(t, source, id), findBook(t, target, id)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("restored book differs:\n got %+v\nwant %+v", got, want)
+		}
+	}
+}
+
+func TestBackupFailingMidStreamIsNotAValidDump(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+	)
+	// A title that is not a string fails to decode once the cursor reaches it.
+	if _, err := h.collection.InsertOne(testContext(t), bson.M{"title": 5, "author": "Broken"}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/books/backup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(asAdmin[0], asAdmin[1])
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return // dropped before anything was sent
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		if gz, gzErr := gzip.NewReader(bytes.NewReader(body)); gzErr == nil {
+			_, err = io.ReadAll(gz)
+		} else {
+			err = gzErr
+		}
+	}
+	if err == nil {
+		t.Fatalf("a backup that failed part way read as a complete dump (status %d)", resp.StatusCode)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type bestseller struct {
+	Book Book `json:"book" bson:"book"`
+	Sold int  `json:"sold" bson:"sold"`
+}
+
+// bestsellerCache holds the last computed ranking so reads never aggregate.
+type bestsellerCache struct {
+	mu          sync.RWMutex
+	ranking     []bestseller
+	refreshedAt time.Time
+}
+
+// rankBestsellers sums the sales within BestsellerWindow per book, best first.
+func (h *BookHandler) rankBestsellers(ctx context.Context) ([]bestseller, error) {
+	since := h.now().UTC().Add(-h.config.BestsellerWindow)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"soldAt": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$bookId"},
+			{Key: "sold", Value: bson.D{{Key: "$sum", Value: "$quantity"}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "sold", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: h.collection.Name()},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "book"},
+		}}},
+		{{Key: "$unwind", Value: "$book"}},
+		{{Key: "$match", Value: bson.M{"book.deletedAt": notDeleted}}},
+		{{Key: "$limit", Value: h.config.BestsellerLimit}},
+		{{Key: "$unset", Value: "book.reviews"}},
+	}
+	cursor, err := h.sales.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	ranking := make([]bestseller, 0)
+	if err := cursor.All(ctx, &ranking); err != nil {
+		return nil, err
+	}
+	return ranking, nil
+}
+
+// refreshBestsellers recomputes the ranking and swaps it into the cache.
+func (h *BookHandler) refreshBestsellers(ctx context.Context) error {
+	ranking, err := h.rankBestsellers(ctx)
+	if err != nil {
+		return err
+	}
+	h.bestsellers.mu.Lock()
+	h.bestsellers.ranking = ranking
+	h.bestsellers.refreshedAt = h.now().UTC()
+	h.bestsellers.mu.Unlock()
+	return nil
+}
+
+// refreshBestsellersEvery keeps the cache fresh until ctx is cancelled,
+// starting with an immediate refresh.
+func (h *BookHandler) refreshBestsellersEvery(ctx context.Context) {
+	ticker := time.NewTicker(h.config.BestsellerRefresh)
+	defer ticker.Stop()
+	for {
+		refreshCtx, cancel := context.WithTimeout(ctx, h.config.QueryTimeout)
+		if err := h.refreshBestsellers(refreshCtx); err != nil && ctx.Err() == nil {
+			log.Printf("bestsellers: %v", err)
+		}
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Get the cached bestseller ranking
+func (h *BookHandler) getBestsellers(c *gin.Context) {
+	h.bestsellers.mu.RLock()
+	ranking := make([]bestseller, len(h.bestsellers.ranking))
+	copy(ranking, h.bestsellers.ranking)
+	refreshedAt := h.bestsellers.refreshedAt
+	h.bestsellers.mu.RUnlock()
+
+	if refreshedAt.IsZero() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Bestsellers have not been computed yet"})
+		return
+	}
+
+	for i := range ranking {
+		h.presentBook(c, &ranking[i].Book)
+	}
+	render(c, http.StatusOK, gin.H{"refreshedAt": refreshedAt, "books": ranking})
+}
+
+// Recompute the bestseller ranking now rather than at the next interval
+func (h *BookHandler) refreshBestsellersNow(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	if err := h.refreshBestsellers(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing bestsellers"})
+		return
+	}
+
+	h.getBestsellers(c)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBestsellersReflectSalesAfterARefresh(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) {
+		cfg.BestsellerWindow = 7 * 24 * time.Hour
+		cfg.BestsellerLimit = 10
+	})
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+		Book{Title: "Hyperion", Author: "Dan Simmons"},
+	)
+	now := time.Now()
+	sell := func(book, quantity int, age time.Duration) {
+		t.Helper()
+		if _, err := h.sales.InsertOne(testContext(t), Sale{BookID: ids[book], Quantity: quantity, SoldAt: now.Add(-age)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranking := func() []string {
+		t.Helper()
+		w := serve(router, http.MethodGet, "/books/bestsellers", nil)
+		expectStatus(t, w, http.StatusOK)
+		var body struct {
+			Books []bestseller `json:"books"`
+		}
+		decodeBody(t, w, &body)
+		got := []string{}
+		for _, b := range body.Books {
+			got = append(got, fmt.Sprintf("%s:%d", b.Book.Title, b.Sold))
+		}
+		return got
+	}
+	refresh := func() {
+		t.Helper()
+		expectStatus(t, serve(router, http.MethodPost, "/admin/bestsellers/refresh", nil, asAdmin...), http.StatusOK)
+	}
+
+	expectStatus(t, serve(router, http.MethodGet, "/books/bestsellers", nil), http.StatusServiceUnavailable)
+
+	sell(0, 5, time.Hour)
+	sell(1, 8, time.Hour)
+	sell(1, 4, 48*time.Hour)
+	// Too old to count.
+	sell(2, 50, 30*24*time.Hour)
+	refresh()
+	if got, want := ranking(), []string{"Emma:12", "Dune:5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranking = %v, want %v", got, want)
+	}
+
+	// Reads are served from the cache until the next refresh.
+	sell(0, 10, time.Minute)
+	if got, want := ranking(), []string{"Emma:12", "Dune:5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranking before refresh = %v, want %v", got, want)
+	}
+	refresh()
+	if got, want := ranking(), []string{"Dune:15", "Emma:12"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranking after refresh = %v, want %v", got, want)
+	}
+}
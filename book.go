@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Book struct {
+	ID     primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Title  string             `json:"title" bson:"title"`
+	Author string             `json:"author" bson:"author"`
+	ISBN   string             `json:"isbn,omitempty" bson:"isbn,omitempty"`
+	Price  float64            `json:"price" bson:"price"`
+
+	PublishedAt  *time.Time `json:"publishedAt,omitempty" bson:"publishedAt,omitempty"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"` // set on insert
+	OutOfPrint   bool       `json:"outOfPrint" bson:"outOfPrint,omitempty"`         // omitempty: a PUT never clears it
+	Stock        int        `json:"stock" bson:"stock,omitempty"`                   // adjusted through sell/restock
+	ReorderPoint int        `json:"reorderPoint,omitempty" bson:"reorderPoint,omitempty"`
+	Location     *Location  `json:"location,omitempty" bson:"location,omitempty"`
+	Position     int        `json:"position,omitempty" bson:"position,omitempty"` // set through /books/reorder
+	WeightGrams  int        `json:"weightGrams,omitempty" bson:"weightGrams,omitempty"`
+	Tags         []string   `json:"tags,omitempty" bson:"tags,omitempty"`
+	Genre        string     `json:"genre,omitempty" bson:"genre,omitempty"` // lower-cased on write
+	WordCount    int        `json:"wordCount,omitempty" bson:"wordCount,omitempty"`
+
+	// Cost and Supplier are admin-only: they are dropped from responses to
+	// other callers and ignored when those callers write them.
+	Cost     float64 `json:"cost,omitempty" bson:"cost,omitempty"`
+	Supplier string  `json:"supplier,omitempty" bson:"supplier,omitempty"`
+
+	// StockByLocation breaks Stock down per branch. Stock stays the total,
+	// so copies not assigned to any branch are Stock minus the sum.
+	StockByLocation map[string]int `json:"stockByLocation,omitempty" bson:"stockByLocation,omitempty"`
+
+	// ReadingMinutes is computed from WordCount on the way out, never stored.
+	ReadingMinutes int `json:"readingMinutes,omitempty" bson:"-"`
+
+	// Translations maps a lower-cased locale ("fr", "pt-br") to a localized
+	// title, served in place of Title per Accept-Language.
+	Translations map[string]string `json:"translations,omitempty" bson:"translations,omitempty"`
+
+	// Reviews and the aggregates derived from them are maintained by the
+	// review routes only; bindBook drops any values sent by clients.
+	Reviews       []Review `json:"reviews,omitempty" bson:"reviews,omitempty"`
+	AverageRating float64  `json:"averageRating,omitempty" bson:"averageRating,omitempty"`
+	ReviewCount   int      `json:"reviewCount,omitempty" bson:"reviewCount,omitempty"`
+
+	// DeletedAt marks a soft-deleted book when SOFT_DELETE_RETENTION is set.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+
+	// visible, when set by presentBook, limits the JSON fields written out
+	// to those of the request's API version.
+	visible map[string]bool
+}
+
+// notDeleted filters out soft-deleted books; match it against "deletedAt".
+var notDeleted = bson.M{"$exists": false}
+
+// Location is where a physical copy sits in the library.
+type Location struct {
+	Shelf string `json:"shelf" bson:"shelf"`
+	Row   int    `json:"row,omitempty" bson:"row,omitempty"`
+}
+
+// FieldError describes one business-rule failure on a submitted book.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validate checks the rules a well-formed book must satisfy before it is stored.
+func (b Book) validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(b.Title) == "" {
+		errs = append(errs, FieldError{Field: "title", Message: "is required"})
+	}
+	if strings.TrimSpace(b.Author) == "" {
+		errs = append(errs, FieldError{Field: "author", Message: "is required"})
+	}
+	if b.ISBN != "" && !validISBN(b.ISBN) {
+		errs = append(errs, FieldError{Field: "isbn", Message: "must be a 10 or 13 digit ISBN"})
+	}
+	if b.Price < 0 {
+		errs = append(errs, FieldError{Field: "price", Message: "must not be negative"})
+	}
+	if b.Cost < 0 {
+		errs = append(errs, FieldError{Field: "cost", Message: "must not be negative"})
+	}
+	if b.Stock < 0 {
+		errs = append(errs, FieldError{Field: "stock", Message: "must not be negative"})
+	}
+	if b.ReorderPoint < 0 {
+		errs = append(errs, FieldError{Field: "reorderPoint", Message: "must not be negative"})
+	}
+	if b.WordCount < 0 {
+		errs = append(errs, FieldError{Field: "wordCount", Message: "must not be negative"})
+	}
+	if b.WeightGrams < 0 {
+		errs = append(errs, FieldError{Field: "weightGrams", Message: "must not be negative"})
+	}
+	for i, tag := range b.Tags {
+		if strings.TrimSpace(tag) == "" {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("tags[%d]", i), Message: "must not be blank"})
+		}
+	}
+	for locale, title := range b.Translations {
+		if locale == "" || locale != strings.ToLower(locale) || strings.TrimSpace(title) == "" {
+			errs = append(errs, FieldError{Field: "translations." + locale, Message: "must map a lower-case locale to a title"})
+		}
+	}
+	assigned := 0
+	for location, stock := range b.StockByLocation {
+		if !validLocationKey(location) {
+			errs = append(errs, FieldError{Field: "stockByLocation." + location, Message: "must be a non-blank name without dots or a leading $"})
+		}
+		if stock < 0 {
+			errs = append(errs, FieldError{Field: "stockByLocation." + location, Message: "must not be negative"})
+		}
+		assigned += stock
+	}
+	if assigned > b.Stock {
+		errs = append(errs, FieldError{Field: "stockByLocation", Message: "must not add up to more than stock"})
+	}
+	if b.Location != nil {
+		if strings.TrimSpace(b.Location.Shelf) == "" {
+			errs = append(errs, FieldError{Field: "location.shelf", Message: "is required"})
+		}
+		if b.Location.Row < 0 {
+			errs = append(errs, FieldError{Field: "location.row", Message: "must not be negative"})
+		}
+	}
+	return errs
+}
+
+// normalizeISBN strips the hyphens and spaces ISBNs are often printed with.
+func normalizeISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+}
+
+// normalizeAuthor trims an author name and collapses runs of whitespace.
+// A name typed entirely in one case is recased word by word ("jean-paul
+// SARTRE" -> "Jean-Paul Sartre"); mixed case is kept, so "McEwan" survives.
+func normalizeAuthor(author string) string {
+	words := strings.Fields(author)
+	for i, word := range words {
+		if word == strings.ToLower(word) || word == strings.ToUpper(word) {
+			words[i] = titleCase(word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// titleCase upper-cases the first letter of the word and every letter after
+// a hyphen, apostrophe or period, lower-casing the rest.
+func titleCase(word string) string {
+	runes := []rune(strings.ToLower(word))
+	upper := true
+	for i, r := range runes {
+		if upper {
+			runes[i] = unicode.ToUpper(r)
+		}
+		upper = r == '-' || r == '\'' || r == '.'
+	}
+	return string(runes)
+}
+
+// normalizeGenre lower-cases a genre so it matches KNOWN_GENRES.
+func normalizeGenre(genre string) string {
+	return strings.ToLower(strings.TrimSpace(genre))
+}
+
+// validISBN checks the shape of a normalized ISBN-10 or ISBN-13.
+func validISBN(isbn string) bool {
+	switch len(isbn) {
+	case 10:
+		return allDigits(isbn[:9]) && (allDigits(isbn[9:]) || isbn[9] == 'X')
+	case 13:
+		return allDigits(isbn)
+	}
+	return false
+}
+
+// unassignedStock is the part of Stock not held by any location.
+func (b Book) unassignedStock() int {
+	unassigned := b.Stock
+	for _, stock := range b.StockByLocation {
+		unassigned -= stock
+	}
+	return max(unassigned, 0)
+}
+
+// validLocationKey reports whether a branch name can be used as a field
+// name under stockByLocation.
+func validLocationKey(location string) bool {
+	return strings.TrimSpace(location) != "" && !strings.Contains(location, ".") && !strings.HasPrefix(location, "$")
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
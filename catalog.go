@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pdf/fpdf"
+)
+
+// maxCatalogBooks bounds how many books one printed catalog lists.
+const maxCatalogBooks = 1000
+
+var catalogHTML = template.Must(template.New("catalog").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Catalog</title>
+<style>
+body { font-family: serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+td.price { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Catalog</h1>
+<p>{{len .Books}} books, {{.GeneratedAt.Format "2 January 2006"}}</p>
+<table>
+<thead><tr><th>Title</th><th>Author</th><th>ISBN</th><th>Price</th></tr></thead>
+<tbody>
+{{- range .Books}}
+<tr><td>{{.Title}}</td><td>{{.Author}}</td><td>{{.ISBN}}</td><td class="price">{{printf "%.2f" .Price}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// Render the filtered listing as a printable catalog (?format=html|pdf)
+func (h *BookHandler) getCatalog(c *gin.Context) {
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or pdf"})
+		return
+	}
+	filter, opts, err := h.listQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.SetLimit(maxCatalogBooks)
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	books, err := h.findBooksIn(ctx, h.reader(c), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	h.presentBooks(c, books)
+	generatedAt := h.now().UTC()
+
+	var buf bytes.Buffer
+	if format == "html" {
+		err = catalogHTML.Execute(&buf, struct {
+			Books       []Book
+			GeneratedAt time.Time
+		}{books, generatedAt})
+	} else {
+		err = catalogPDF(&buf, books, generatedAt)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error rendering catalog"})
+		return
+	}
+
+	if format == "html" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+		return
+	}
+	filename := fmt.Sprintf("catalog-%s.pdf", generatedAt.Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// catalogPDF lays the books out as an A4 table. The core fonts only cover
+// cp1252, so text is translated and anything outside it is lost.
+func catalogPDF(buf *bytes.Buffer, books []Book, generatedAt time.Time) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	widths := []float64{80, 50, 35, 25}
+
+	header := func() {
+		pdf.SetFont("Helvetica", "B", 10)
+		for i, name := range []string{"Title", "Author", "ISBN", "Price"} {
+			pdf.CellFormat(widths[i], 7, name, "B", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 9)
+	}
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, "Page "+strconv.Itoa(pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Catalog", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%d books, %s", len(books), generatedAt.Format("2 January 2006")), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+	header()
+	// Pages after the first repeat the column headings.
+	pdf.SetHeaderFunc(header)
+
+	fit := func(s string, width float64) string {
+		s = tr(s)
+		for len(s) > 0 && pdf.GetStringWidth(s) > width-2 {
+			s = s[:len(s)-1]
+		}
+		return s
+	}
+	for _, book := range books {
+		pdf.CellFormat(widths[0], 6, fit(book.Title, widths[0]), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 6, fit(book.Author, widths[1]), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, book.ISBN, "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], 6, fmt.Sprintf("%.2f", book.Price), "", 1, "R", false, 0, "")
+	}
+
+	return pdf.Output(buf)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCatalogHTMLListsTheFilteredTitles(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "Hyperion", Author: "Dan Simmons", Price: 8},
+		Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99},
+		Book{Title: "Tom & Jerry <Annotated>", Author: "Anon", Price: 4},
+		Book{Title: "Emma", Author: "Jane Austen", Price: 30},
+	)
+
+	w := serve(router, http.MethodGet, "/books/catalog?format=html&sort=title&tier=budget", nil)
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	page := w.Body.String()
+	// The titles appear, escaped, in listing order; Emma is filtered out.
+	last := -1
+	for _, title := range []string{"Dune", "Hyperion", "Tom &amp; Jerry &lt;Annotated&gt;"} {
+		i := strings.Index(page, "<td>"+title+"</td>")
+		if i < 0 {
+			t.Fatalf("catalog is missing %q:\n%s", title, page)
+		}
+		if i < last {
+			t.Fatalf("%q is out of order:\n%s", title, page)
+		}
+		last = i
+	}
+	if strings.Contains(page, "Emma") {
+		t.Fatalf("catalog lists a book outside the filter:\n%s", page)
+	}
+
+	w = serve(router, http.MethodGet, "/books/catalog?format=pdf", nil)
+	expectStatus(t, w, http.StatusOK)
+	if ct, cd := w.Header().Get("Content-Type"), w.Header().Get("Content-Disposition"); ct != "application/pdf" || !strings.Contains(cd, ".pdf") {
+		t.Fatalf("Content-Type = %q, Content-Disposition = %q", ct, cd)
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatal("body is not a PDF")
+	}
+
+	expectStatus(t, serve(router, http.MethodGet, "/books/catalog?format=docx", nil), http.StatusBadRequest)
+}
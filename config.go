@@ -0,0 +1,337 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Config holds the runtime settings, read from the environment at startup.
+type Config struct {
+	MongoURI     string
+	Database     string
+	Collection   string
+	Port         string
+	QueryTimeout time.Duration
+
+	// ShutdownTimeout bounds how long in-flight requests may drain on exit.
+	ShutdownTimeout time.Duration
+
+	// PublicURL is the externally visible base URL, used in generated links.
+	PublicURL string
+
+	// MaxReviews caps the reviews embedded in a book; ReviewEviction picks
+	// which ones are dropped past the cap ("oldest" or "lowest").
+	MaxReviews     int
+	ReviewEviction string
+
+	// RestockWindow is how far back sales are averaged to estimate velocity;
+	// books expected to sell out within RestockThresholdDays are suggested.
+	RestockWindow        time.Duration
+	RestockThresholdDays float64
+
+	// RequireIfMatch rejects a PUT without an If-Match header (428) instead
+	// of applying it unconditionally.
+	RequireIfMatch bool
+
+	// SoftDeleteRetention, when positive, turns DELETE into a soft delete and
+	// has a TTL index on deletedAt purge the book after this long.
+	SoftDeleteRetention time.Duration
+
+	// PriceTiers are the named price bands for ?tier=, in ascending order.
+	PriceTiers []PriceTier
+
+	// SchemaDriftFields are the fields /admin/schema-drift checks for; each
+	// must have an entry in schemaBackfills.
+	SchemaDriftFields []string
+
+	// DefaultSort is the sortOrders key applied when a listing has no ?sort=;
+	// empty leaves the natural order.
+	DefaultSort string
+
+	// WordsPerMinute is the reading speed behind readingMinutes.
+	WordsPerMinute int
+
+	// WebhookURLs receive book change events. An event already delivered to
+	// a URL is not sent there again within WebhookDedupWindow; failed
+	// deliveries are retried up to WebhookMaxAttempts times.
+	WebhookURLs        []string
+	WebhookDedupWindow time.Duration
+	WebhookMaxAttempts int
+
+	// UniqueOn lists the fields that must be unique together ("isbn", or
+	// "title,author"); empty means no uniqueness constraint.
+	UniqueOn []string
+
+	// MaxBooksPerAuthor caps how many books one author may have listed;
+	// 0 leaves it unlimited.
+	MaxBooksPerAuthor int
+
+	// TombstoneRetention is how long a deleted book's ID keeps answering 410
+	// Gone instead of 404, counted from when it is purged; 0 disables it.
+	TombstoneRetention time.Duration
+
+	// The bestseller ranking sums sales over BestsellerWindow, keeps the top
+	// BestsellerLimit books and is recomputed every BestsellerRefresh.
+	BestsellerWindow  time.Duration
+	BestsellerLimit   int
+	BestsellerRefresh time.Duration
+
+	// AdminToken is the bearer token that grants the admin role; empty means
+	// nobody is an admin.
+	AdminToken string
+
+	// ISBNLookupURL is the base URL of the metadata service behind
+	// /books/import-by-isbn, sent ISBNLookupKey as X-API-Key; empty disables
+	// the route.
+	ISBNLookupURL string
+	ISBNLookupKey string
+
+	// DefaultAPIVersion applies to requests without an API-Version header;
+	// V1Fields are the only book fields API version 1 responses carry.
+	DefaultAPIVersion string
+	V1Fields          []string
+
+	// KnownGenres are the genres /books/coverage reports on, lower-case.
+	KnownGenres []string
+
+	// ReadConcern is the read concern for reads without ?read_concern=.
+	ReadConcern string
+}
+
+// PriceTier is a named price band, Min inclusive and Max exclusive. A zero
+// Max leaves the top tier open-ended.
+type PriceTier struct {
+	Name string
+	Min  float64
+	Max  float64
+}
+
+func (t PriceTier) filter() bson.M {
+	price := bson.M{"$gte": t.Min}
+	if t.Max > 0 {
+		price["$lt"] = t.Max
+	}
+	return bson.M{"price": price}
+}
+
+func (cfg Config) priceTier(name string) (PriceTier, bool) {
+	for _, tier := range cfg.PriceTiers {
+		if tier.Name == name {
+			return tier, true
+		}
+	}
+	return PriceTier{}, false
+}
+
+func loadConfig() Config {
+	cfg := Config{
+		MongoURI:     getEnv("MONGO_URI", "mongodb://localhost:27017"),
+		Database:     getEnv("MONGO_DATABASE", "library"),
+		Collection:   getEnv("MONGO_COLLECTION", "books"),
+		Port:         getEnv("PORT", "8000"),
+		QueryTimeout: getEnvDuration("QUERY_TIMEOUT", 10*time.Second),
+
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		MaxReviews:     getEnvInt("MAX_REVIEWS", 100),
+		ReviewEviction: getEnvChoice("REVIEW_EVICTION", evictOldest, evictOldest, evictLowest),
+
+		RestockWindow:        getEnvDuration("RESTOCK_WINDOW", 30*24*time.Hour),
+		RestockThresholdDays: getEnvFloat("RESTOCK_THRESHOLD_DAYS", 14),
+
+		RequireIfMatch: getEnvBool("REQUIRE_IF_MATCH", false),
+
+		SoftDeleteRetention: getEnvDuration("SOFT_DELETE_RETENTION", 0),
+
+		PriceTiers: getEnvPriceTiers("PRICE_TIER_BOUNDARIES", 10, 25),
+
+		SchemaDriftFields: getEnvList("SCHEMA_DRIFT_FIELDS", "createdAt"),
+
+		DefaultSort: getEnv("DEFAULT_SORT", ""),
+
+		WordsPerMinute: getEnvInt("WORDS_PER_MINUTE", 250),
+
+		WebhookURLs:        getEnvList("WEBHOOK_URLS", ""),
+		WebhookDedupWindow: getEnvDuration("WEBHOOK_DEDUP_WINDOW", 5*time.Minute),
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+
+		UniqueOn: getEnvList("UNIQUE_ON", ""),
+
+		MaxBooksPerAuthor: getEnvInt("MAX_BOOKS_PER_AUTHOR", 0),
+
+		TombstoneRetention: getEnvDuration("TOMBSTONE_RETENTION", 30*24*time.Hour),
+
+		BestsellerWindow:  getEnvDuration("BESTSELLER_WINDOW", 30*24*time.Hour),
+		BestsellerLimit:   getEnvInt("BESTSELLER_LIMIT", 20),
+		BestsellerRefresh: getEnvDuration("BESTSELLER_REFRESH", 10*time.Minute),
+
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		ISBNLookupURL: strings.TrimRight(getEnv("ISBN_LOOKUP_URL", ""), "/"),
+		ISBNLookupKey: getEnv("ISBN_LOOKUP_KEY", ""),
+
+		DefaultAPIVersion: getEnv("DEFAULT_API_VERSION", "2"),
+		V1Fields:          getEnvList("API_V1_FIELDS", "id,title,author,price"),
+
+		ReadConcern: getEnvChoice("READ_CONCERN", "local", "local", "majority", "linearizable"),
+
+		KnownGenres: getEnvList("KNOWN_GENRES", "fiction,mystery,science-fiction,fantasy,romance,biography,history,science,poetry,children"),
+	}
+
+	cfg.PublicURL = strings.TrimRight(getEnv("PUBLIC_URL", "http://localhost:"+cfg.Port), "/")
+
+	if _, ok := sortOrders[cfg.DefaultSort]; cfg.DefaultSort != "" && !ok {
+		log.Fatalf("invalid DEFAULT_SORT: unknown sort %q", cfg.DefaultSort)
+	}
+	for _, field := range cfg.SchemaDriftFields {
+		if _, ok := schemaBackfills[field]; !ok {
+			log.Fatalf("invalid SCHEMA_DRIFT_FIELDS: no backfill known for %q", field)
+		}
+	}
+	if cfg.WordsPerMinute < 1 {
+		log.Fatalf("invalid WORDS_PER_MINUTE: must be at least 1")
+	}
+	for _, field := range cfg.UniqueOn {
+		if !uniqueFields[field] {
+			log.Fatalf("invalid UNIQUE_ON: %q cannot be made unique", field)
+		}
+	}
+	if cfg.WebhookMaxAttempts < 1 {
+		log.Fatalf("invalid WEBHOOK_MAX_ATTEMPTS: must be at least 1")
+	}
+	if cfg.MaxReviews < 1 {
+		log.Fatalf("invalid MAX_REVIEWS: must be at least 1")
+	}
+	if cfg.RestockWindow < 24*time.Hour {
+		log.Fatalf("invalid RESTOCK_WINDOW: must be at least 24h")
+	}
+	if cfg.BestsellerLimit < 1 {
+		log.Fatalf("invalid BESTSELLER_LIMIT: must be at least 1")
+	}
+	if cfg.BestsellerRefresh <= 0 || cfg.BestsellerWindow <= 0 {
+		log.Fatalf("invalid BESTSELLER_REFRESH or BESTSELLER_WINDOW: must be positive")
+	}
+	if !apiVersions[cfg.DefaultAPIVersion] {
+		log.Fatalf("invalid DEFAULT_API_VERSION: unknown version %q", cfg.DefaultAPIVersion)
+	}
+	for _, field := range cfg.V1Fields {
+		if !bookFields[field] {
+			log.Fatalf("invalid API_V1_FIELDS: %q is not a book field", field)
+		}
+	}
+	for i, genre := range cfg.KnownGenres {
+		cfg.KnownGenres[i] = normalizeGenre(genre)
+	}
+	if cfg.MaxBooksPerAuthor < 0 {
+		log.Fatalf("invalid MAX_BOOKS_PER_AUTHOR: must not be negative")
+	}
+
+	return cfg
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return d
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return n
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return f
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return b
+}
+
+func getEnvList(key, fallback string) []string {
+	var out []string
+	for _, part := range strings.Split(getEnv(key, fallback), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func getEnvChoice(key, fallback string, allowed ...string) string {
+	value := getEnv(key, fallback)
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	log.Fatalf("invalid %s: %q is not one of %s", key, value, strings.Join(allowed, ", "))
+	return ""
+}
+
+// getEnvPriceTiers reads the two ascending boundaries ("10,25") splitting
+// prices into the budget, mid and premium tiers.
+func getEnvPriceTiers(key string, low, high float64) []PriceTier {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		parts := strings.Split(value, ",")
+		if len(parts) != 2 {
+			log.Fatalf("invalid %s: want two comma-separated boundaries", key)
+		}
+		var err error
+		if low, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err != nil {
+			log.Fatalf("invalid %s: %v", key, err)
+		}
+		if high, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil {
+			log.Fatalf("invalid %s: %v", key, err)
+		}
+	}
+	if low <= 0 || high <= low {
+		log.Fatalf("invalid %s: boundaries must be positive and ascending", key)
+	}
+	return []PriceTier{
+		{Name: "budget", Min: 0, Max: low},
+		{Name: "mid", Min: low, Max: high},
+		{Name: "premium", Min: high},
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bookETag derives a strong ETag from the stored book, so a change made by
+// any route yields a new tag.
+func bookETag(book Book) (string, error) {
+	data, err := bson.Marshal(book)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// etagMatches reports whether an If-Match header value covers etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// unchangedFilter matches the document only while every stored field still
+// holds the value in current, turning an update into a compare-and-swap.
+func unchangedFilter(current bson.Raw) (bson.D, error) {
+	elems, err := current.Elements()
+	if err != nil {
+		return nil, err
+	}
+	filter := make(bson.D, 0, len(elems))
+	for _, e := range elems {
+		filter = append(filter, bson.E{Key: e.Key(), Value: e.Value()})
+	}
+	return filter, nil
+}
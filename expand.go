@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Author is a document in the authors collection, matched to books by name.
+type Author struct {
+	ID    primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name  string             `json:"name" bson:"name"`
+	Bio   string             `json:"bio,omitempty" bson:"bio,omitempty"`
+	Photo string             `json:"photo,omitempty" bson:"photo,omitempty"`
+}
+
+// expandable are the accepted ?expand= values on getBookByID.
+var expandable = map[string]bool{"reviews": true, "author": true, "similar": true}
+
+// maxSimilarBooks bounds the ?expand=similar list.
+const maxSimilarBooks = 5
+
+// expandedBook is a book with its requested sub-resources under "_expanded".
+type expandedBook struct {
+	Book
+	Expanded map[string]interface{} `json:"_expanded"`
+}
+
+// MarshalJSON adds _expanded to the book's own fields; without it the
+// promoted Book.MarshalJSON would leave it out.
+func (e expandedBook) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(e.Book)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields["_expanded"], err = json.Marshal(e.Expanded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// authoredBook is a listed book with its author document joined in place of
+// the author's name; Author is nil when no author document matches.
+type authoredBook struct {
+	Book   `bson:",inline"`
+	Author *Author `json:"author" bson:"authorDoc"`
+}
+
+// MarshalJSON swaps the joined author in for the name; without it the
+// promoted Book.MarshalJSON would write the name.
+func (b authoredBook) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(b.Book)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["author"]; ok {
+		if fields["author"], err = json.Marshal(b.Author); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// findBooksWithAuthors runs a listing as an aggregation that joins each
+// book's author document by name.
+func (h *BookHandler) findBooksWithAuthors(ctx context.Context, collection *mongo.Collection, filter interface{}, opts *options.FindOptions) ([]authoredBook, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	if opts.Sort != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: opts.Sort}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: h.authors.Name()},
+			{Key: "localField", Value: "author"},
+			{Key: "foreignField", Value: "name"},
+			{Key: "as", Value: "authorDoc"},
+		}}},
+		// An unmatched name leaves authorDoc missing, decoded as a nil Author.
+		bson.D{{Key: "$set", Value: bson.M{"authorDoc": bson.M{"$arrayElemAt": bson.A{"$authorDoc", 0}}}}},
+	)
+
+	aggOpts := options.Aggregate()
+	if opts.Hint != nil {
+		aggOpts.SetHint(opts.Hint)
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, aggOpts)
+	if err != nil {
+		return nil, err
+	}
+	books := make([]authoredBook, 0)
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// parseExpand validates a comma-separated ?expand= value.
+func parseExpand(values []string) (map[string]bool, error) {
+	expand := map[string]bool{}
+	for _, name := range splitQueryList(values) {
+		if !expandable[name] {
+			return nil, fmt.Errorf("unknown expand %q", name)
+		}
+		expand[name] = true
+	}
+	return expand, nil
+}
+
+// expandBook fetches each requested sub-resource of book, reading other books
+// through books, the request's reader.
+func (h *BookHandler) expandBook(ctx context.Context, books *mongo.Collection, book Book, expand map[string]bool) (map[string]interface{}, error) {
+	expanded := make(map[string]interface{}, len(expand))
+
+	if expand["reviews"] {
+		reviews := book.Reviews
+		if reviews == nil {
+			reviews = []Review{}
+		}
+		expanded["reviews"] = reviews
+	}
+
+	if expand["author"] {
+		var author Author
+		err := h.authors.FindOne(ctx, bson.M{"name": book.Author}).Decode(&author)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			expanded["author"] = nil
+		case err != nil:
+			return nil, err
+		default:
+			expanded["author"] = author
+		}
+	}
+
+	if expand["similar"] {
+		// Same author or any shared tag, best rated first.
+		anyOf := bson.A{bson.M{"author": book.Author}}
+		if len(book.Tags) > 0 {
+			anyOf = append(anyOf, bson.M{"tags": bson.M{"$in": book.Tags}})
+		}
+		similar, err := h.findBooksIn(ctx, books,
+			bson.M{"_id": bson.M{"$ne": book.ID}, "deletedAt": notDeleted, "$or": anyOf},
+			options.Find().
+				SetSort(sortOrders["rating"]).
+				SetLimit(maxSimilarBooks).
+				SetProjection(bson.M{"reviews": 0}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		expanded["similar"] = similar
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetBookByIDKeepsReviewsAndExpandsThem(t *testing.T) {
+	h, router := newTestHandler(t)
+	reviews := []Review{{Reviewer: "ann", Rating: 4}, {Reviewer: "bob", Rating: 2}}
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Reviews: reviews})
+	path := "/books/" + ids[0].Hex()
+
+	// The default response is unchanged: reviews included, no _expanded.
+	w := serve(router, http.MethodGet, path, nil)
+	expectStatus(t, w, http.StatusOK)
+	var plain map[string]interface{}
+	decodeBody(t, w, &plain)
+	if got, _ := plain["reviews"].([]interface{}); len(got) != 2 {
+		t.Fatalf("default reviews = %v, want 2", plain["reviews"])
+	}
+	if _, ok := plain["_expanded"]; ok {
+		t.Fatal("default response has _expanded")
+	}
+
+	w = serve(router, http.MethodGet, path+"?expand=reviews", nil)
+	expectStatus(t, w, http.StatusOK)
+	var expanded struct {
+		Reviews  []Review `json:"reviews"`
+		Expanded struct {
+			Reviews []Review `json:"reviews"`
+		} `json:"_expanded"`
+	}
+	decodeBody(t, w, &expanded)
+	if len(expanded.Reviews) != 2 || len(expanded.Expanded.Reviews) != 2 || expanded.Expanded.Reviews[1].Reviewer != "bob" {
+		t.Fatalf("expanded = %+v, want both reviews in place and under _expanded", expanded)
+	}
+}
+
+func TestListingWithAuthorJoinsAuthorDocuments(t *testing.T) {
+	h, router := newTestHandler(t)
+	herbert := Author{ID: primitive.NewObjectID(), Name: "Frank Herbert", Bio: "American science fiction author.", Photo: "https://example.com/herbert.jpg"}
+	if _, err := h.authors.InsertOne(testContext(t), herbert); err != nil {
+		t.Fatal(err)
+	}
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+	)
+
+	w := serve(router, http.MethodGet, "/books?with=author&sort=title", nil)
+	expectStatus(t, w, http.StatusOK)
+	var books []struct {
+		Title  string  `json:"title"`
+		Author *Author `json:"author"`
+	}
+	decodeBody(t, w, &books)
+	if len(books) != 2 {
+		t.Fatalf("got %d books, want 2", len(books))
+	}
+	if books[0].Title != "Dune" || books[0].Author == nil || *books[0].Author != herbert {
+		t.Errorf("Dune author = %+v, want %+v", books[0].Author, herbert)
+	}
+	// No authors document for Jane Austen, so her book's author is null.
+	if books[1].Title != "Emma" || books[1].Author != nil {
+		t.Errorf("Emma author = %+v, want null", books[1].Author)
+	}
+
+	expectStatus(t, serve(router, http.MethodGet, "/books?with=publisher", nil), http.StatusBadRequest)
+}
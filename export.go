@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Export the books matching the listing filters as an xlsx workbook
+func (h *BookHandler) exportBooksXLSX(c *gin.Context) {
+	filter, opts, err := h.listQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	cursor, err := h.reader(c).Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	f := excelize.NewFile()
+	defer f.Close()
+	const sheet = "Books"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building workbook"})
+		return
+	}
+
+	// The stream writer spills rows to disk past a threshold, so large exports
+	// do not hold every cell in memory.
+	sw, err := f.NewStreamWriter(sheet)
+	if err == nil {
+		err = sw.SetRow("A1", []interface{}{"ID", "Title", "Author", "Price", "Stock"})
+	}
+	for row := 2; err == nil && cursor.Next(ctx); row++ {
+		var book Book
+		if err = cursor.Decode(&book); err != nil {
+			break
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		err = sw.SetRow(cell, []interface{}{book.ID.Hex(), book.Title, book.Author, book.Price, book.Stock})
+	}
+	if err == nil {
+		err = cursor.Err()
+	}
+	if err == nil {
+		err = sw.Flush()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building workbook"})
+		return
+	}
+
+	filename := fmt.Sprintf("books-%s.xlsx", h.now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", xlsxContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+	if err := f.Write(c.Writer); err != nil {
+		log.Printf("export: write xlsx: %v", err)
+	}
+}
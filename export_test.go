@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportXLSXHonorsFiltersAndTypes(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "Emma", Author: "Jane Austen", Price: 7.5, Stock: 3},
+		Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99, Stock: 4},
+	)
+
+	w := serve(router, http.MethodGet, "/books/export.xlsx?search=dune", nil)
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != xlsxContentType {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") || !strings.Contains(cd, ".xlsx") {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+
+	f, err := excelize.OpenReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows("Books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0][1] != "Title" || rows[1][1] != "Dune" {
+		t.Fatalf("rows = %v, want the header and Dune only", rows)
+	}
+	price, err := f.GetCellValue("Books", "D2", excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := f.GetCellType("Books", "D2"); price != "9.99" || typ == excelize.CellTypeSharedString || typ == excelize.CellTypeInlineString {
+		t.Fatalf("D2 = %q (type %v), want the number 9.99", price, typ)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// generationHeader carries the catalog generation on listing responses.
+const generationHeader = "X-Catalog-Generation"
+
+// catalogMetaID is the _id of the meta document holding the generation.
+const catalogMetaID = "catalog"
+
+// bumpGeneration increments the catalog generation, so clients polling the
+// listing can tell whether anything changed. Every handler that modifies
+// books calls it once the write has landed; requests that change nothing,
+// such as dry runs or reports, leave the generation alone. A failed bump is
+// only logged, since the write itself has succeeded.
+func (h *BookHandler) bumpGeneration(ctx context.Context) {
+	_, err := h.meta.UpdateOne(
+		ctx,
+		bson.M{"_id": catalogMetaID},
+		bson.M{"$inc": bson.M{"generation": int64(1)}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("catalog generation: %v", err)
+	}
+}
+
+// generation reads the current catalog generation; 0 before the first write.
+func (h *BookHandler) generation(ctx context.Context) (int64, error) {
+	var meta struct {
+		Generation int64 `bson:"generation"`
+	}
+	err := h.meta.FindOne(ctx, bson.M{"_id": catalogMetaID}).Decode(&meta)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return meta.Generation, err
+}
+
+// generationUnchanged sets the generation header and reports whether the
+// request's If-Generation-Match names the current generation, in which case
+// it has already answered 304.
+func (h *BookHandler) generationUnchanged(ctx context.Context, c *gin.Context) (bool, error) {
+	gen, err := h.generation(ctx)
+	if err != nil {
+		return false, err
+	}
+	current := strconv.FormatInt(gen, 10)
+	c.Header(generationHeader, current)
+	if strings.TrimSpace(c.GetHeader("If-Generation-Match")) == current {
+		c.Status(http.StatusNotModified)
+		return true, nil
+	}
+	return false, nil
+}
+
+// Get the current catalog generation
+func (h *BookHandler) getGeneration(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	gen, err := h.generation(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving generation"})
+		return
+	}
+
+	c.Header(generationHeader, strconv.FormatInt(gen, 10))
+	c.JSON(http.StatusOK, gin.H{"generation": gen})
+}
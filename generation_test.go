@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerationBumpsOnlyOnCatalogWrites(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h, Book{Title: "Dune", Author: " frank  herbert", Price: 9.99})
+
+	w := serve(router, http.MethodGet, "/books", nil)
+	expectStatus(t, w, http.StatusOK)
+	gen := w.Header().Get(generationHeader)
+	if gen != "0" {
+		t.Fatalf("initial generation = %q, want 0", gen)
+	}
+	unchanged := func() *httptest.ResponseRecorder {
+		return serve(router, http.MethodGet, "/books", nil, "If-Generation-Match", gen)
+	}
+	expectStatus(t, unchanged(), http.StatusNotModified)
+
+	// Requests that write nothing to the catalog keep the generation.
+	expectStatus(t, serve(router, http.MethodPost, "/admin/normalize-authors?dry_run=true", nil, asAdmin...), http.StatusOK)
+	expectStatus(t, serve(router, http.MethodPost, "/books/saved-filters", SavedFilter{Name: "cheap", Params: map[string]string{"tier": "budget"}}), http.StatusOK)
+	expectStatus(t, unchanged(), http.StatusNotModified)
+
+	// A real write bumps it.
+	expectStatus(t, serve(router, http.MethodPost, "/admin/normalize-authors", nil, asAdmin...), http.StatusOK)
+	w = unchanged()
+	expectStatus(t, w, http.StatusOK)
+	if got := w.Header().Get(generationHeader); got != "1" {
+		t.Fatalf("generation after write = %q, want 1", got)
+	}
+}
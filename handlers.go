@@ -0,0 +1,694 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/sync/singleflight"
+)
+
+// BookHandler serves the book routes against an injected collection, so tests
+// can point it at a throwaway database.
+type BookHandler struct {
+	collection *mongo.Collection
+	readers    map[string]map[string]*mongo.Collection // by collection name, then read concern level
+	sales      *mongo.Collection
+	authors    *mongo.Collection
+	prices     *mongo.Collection
+	meta       *mongo.Collection
+	tombstones *mongo.Collection
+
+	savedFilters *mongo.Collection
+	config       Config
+	now          func() time.Time
+	lookups      singleflight.Group
+	webhooks     *webhookNotifier
+	isbnClient   *http.Client
+
+	bestsellers bestsellerCache
+}
+
+func NewBookHandler(collection *mongo.Collection, config Config) *BookHandler {
+	sales := collection.Database().Collection("sales")
+	prices := collection.Database().Collection("price_history")
+	return &BookHandler{
+		collection: collection,
+		readers:    newReaders(collection, sales, prices),
+		sales:      sales,
+		authors:    collection.Database().Collection("authors"),
+		prices:     prices,
+		meta:       collection.Database().Collection("meta"),
+		tombstones: tombstones(collection),
+
+		savedFilters: collection.Database().Collection("saved_filters"),
+		config:       config,
+		now:          time.Now,
+		webhooks:     newWebhookNotifier(config),
+		isbnClient:   &http.Client{Timeout: config.QueryTimeout},
+	}
+}
+
+func (h *BookHandler) registerRoutes(router *gin.Engine) {
+	router.GET("/books", h.getBooks)                          // Retrieve all books
+	router.GET("/books/:id", h.getBookByID)                   // Retrieve a specific book by ID
+	router.POST("/books", h.addBook)                          // Add a new book
+	router.PUT("/books/:id", h.updateBook)                    // Update a specific book by ID
+	router.PATCH("/books/:id", h.patchBook)                   // Partially update a book (see patchBook for tag operators)
+	router.DELETE("/books/:id", h.deleteBook)                 // Delete a specific book by ID
+	router.GET("/books/:id/qr", h.getBookQR)                  // PNG QR code linking to the book
+	router.GET("/books/:id/price-history", h.getPriceHistory) // Price changes over time (?from=&to=)
+
+	router.GET("/books/export.xlsx", h.exportBooksXLSX)               // Download the filtered listing as xlsx
+	router.GET("/books/catalog", h.getCatalog)                        // Printable catalog of the filtered listing (?format=html|pdf)
+	router.GET("/books/stream", h.streamBooks)                        // Filtered listing streamed as NDJSON
+	router.GET("/books/ws", h.watchBooksWS)                           // WebSocket of batched change notifications
+	router.POST("/books/import", h.importBooksCSV)                    // Load books from CSV (?progress=true streams NDJSON)
+	router.GET("/books/backup", requireAdmin, h.backupBooks)          // Download a gzipped NDJSON dump (admin)
+	router.POST("/books/restore", requireAdmin, h.restoreBooks)       // Load a dump, ?mode=insert|replace|merge (admin)
+	router.POST("/books/adjust-prices", requireAdmin, h.adjustPrices) // Multiply filtered prices by {"factor": f} (admin)
+
+	router.GET("/books/saved-filters", h.getSavedFilters)            // List saved filters
+	router.POST("/books/saved-filters", h.saveFilter)                // Save a named filter for ?saved_filter=
+	router.DELETE("/books/saved-filters/:name", h.deleteSavedFilter) // Delete a saved filter
+
+	router.GET("/books/by-decade", h.getBooksByDecade)          // Book counts per publication decade
+	router.GET("/books/compare-authors", h.compareAuthors)      // Side-by-side stats for ?a= and ?b=
+	router.GET("/books/stats", h.getStats)                      // Catalog stats, optionally ?from=&to= on createdAt
+	router.GET("/books/coverage", h.getCoverage)                // Books each known genre lacks to reach ?target=
+	router.GET("/books/publication-span", h.getPublicationSpan) // Earliest and latest publication dates
+
+	router.GET("/books/out-of-print", h.getOutOfPrintBooks)  // Retrieve discontinued books
+	router.POST("/books/:id/discontinue", h.discontinueBook) // Mark a book as out of print
+	router.GET("/books/shelf/:shelf", h.getBooksOnShelf)     // Retrieve a shelf's books by title
+	router.POST("/books/reorder", h.reorderBooks)            // Assign manual positions in list order
+	router.POST("/books/search/tag", h.tagSearchResults)     // Tag every book matching a text search
+	router.POST("/books/audit-prices", h.auditPrices)        // Check prices against a reference sheet (?fix=true)
+	router.POST("/books/import-by-isbn", h.importByISBN)     // Create a book from the ISBN metadata service
+
+	router.POST("/books/:id/reviews", h.addReview)     // Add a review to a book
+	router.GET("/books/top-rated", h.getTopRatedBooks) // Best-rated books first (?limit=)
+	router.GET("/books/bestsellers", h.getBestsellers) // Cached ranking by recent sales
+	router.GET("/books/batch", h.getBooksBatch)        // Several books by ?ids=a,b,c
+	router.GET("/books/sample", h.getSample)           // Seeded, repeatable random subset (?n=&seed=)
+	router.GET("/books/generation", h.getGeneration)   // Catalog generation, bumped on every write
+
+	router.POST("/books/:id/sell", h.sellBook)                                 // Sell copies, recording a sale event (?location= for one branch)
+	router.POST("/books/:id/restock", h.restockBook)                           // Add copies to stock (?location= for one branch)
+	router.GET("/books/:id/availability", h.getAvailability)                   // Stock per location and in total
+	router.POST("/books/reserve-cart", h.reserveCart)                          // Reserve stock for a whole cart, all or nothing
+	router.GET("/books/restock-suggestions", h.getRestockSuggestions)          // Books likely to sell out soon
+	router.GET("/books/below-reorder", h.getBooksBelowReorder)                 // Books at or below their reorder point
+	router.GET("/books/:id/frequently-bought-with", h.getFrequentlyBoughtWith) // Top co-purchased books
+}
+
+// Get all books
+func (h *BookHandler) getBooks(c *gin.Context) {
+	filter, opts, err := h.listQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	with := c.Query("with")
+	if with != "" && with != "author" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "with must be author"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// Read the generation before the books: a write landing in between makes
+	// the client refetch next time rather than miss the change.
+	unchanged, err := h.generationUnchanged(ctx, c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	if unchanged {
+		return
+	}
+
+	// ?with=author joins the full author documents server-side.
+	if with == "author" {
+		books, err := h.findBooksWithAuthors(ctx, h.reader(c), filter, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+			return
+		}
+		for i := range books {
+			h.presentBook(c, &books[i].Book)
+		}
+		render(c, http.StatusOK, books)
+		return
+	}
+
+	books, err := h.findBooksIn(ctx, h.reader(c), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+
+	h.renderBooks(c, http.StatusOK, books)
+}
+
+// maxBatchIDs bounds how many books one batch request may fetch.
+const maxBatchIDs = 100
+
+// Get several books by ID, optionally with their summed shipping weight
+func (h *BookHandler) getBooksBatch(c *gin.Context) {
+	ids := splitQueryList(c.QueryArray("ids"))
+	if len(ids) == 0 || len(ids) > maxBatchIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids must list between 1 and 100 IDs"})
+		return
+	}
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID", "id": id})
+			return
+		}
+		objIDs = append(objIDs, objID)
+	}
+
+	totalWeight := false
+	if raw := c.Query("total_weight"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "total_weight must be a boolean"})
+			return
+		}
+		totalWeight = b
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	books, err := h.findBooksIn(ctx, h.reader(c), bson.M{"_id": bson.M{"$in": objIDs}, "deletedAt": notDeleted})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+
+	if !totalWeight {
+		h.renderBooks(c, http.StatusOK, books)
+		return
+	}
+	// Only books that were found count toward the weight.
+	grams := 0
+	for _, book := range books {
+		grams += book.WeightGrams
+	}
+	h.presentBooks(c, books)
+	render(c, http.StatusOK, gin.H{"books": books, "totalWeightGrams": grams})
+}
+
+// Get the best-rated books, unreviewed ones last
+func (h *BookHandler) getTopRatedBooks(c *gin.Context) {
+	limit := int64(10)
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 || n > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = n
+	}
+
+	filter, opts, err := h.listQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts.SetSort(sortOrders["rating"]).SetLimit(limit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	books, err := h.findBooksIn(ctx, h.reader(c), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+
+	h.renderBooks(c, http.StatusOK, books)
+}
+
+// Get the books that have been discontinued
+func (h *BookHandler) getOutOfPrintBooks(c *gin.Context) {
+	_, opts, err := h.listQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	books, err := h.findBooksIn(ctx, h.reader(c), bson.M{"outOfPrint": true, "deletedAt": notDeleted}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+
+	h.renderBooks(c, http.StatusOK, books)
+}
+
+// Get a single book by ID
+func (h *BookHandler) getBookByID(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	expand, err := parseExpand(c.QueryArray("expand"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Concurrent lookups of the same ID at the same read concern share a
+	// single query and its result.
+	level, reader := h.readLevel(c), h.reader(c)
+	result, err, _ := h.lookups.Do("book:"+level+":"+objID.Hex(), func() (interface{}, error) {
+		var book Book
+		ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+		defer cancel()
+
+		err := reader.FindOne(ctx, bson.M{"_id": objID, "deletedAt": notDeleted}).Decode(&book)
+		return book, err
+	})
+	if err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+		defer cancel()
+		if errors.Is(err, mongo.ErrNoDocuments) && h.goneReply(ctx, c, objID) {
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+
+	book := result.(Book)
+	if etag, err := bookETag(book); err == nil {
+		c.Header("ETag", etag)
+	}
+
+	if len(expand) == 0 {
+		h.renderBook(c, http.StatusOK, book)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	expanded, err := h.expandBook(ctx, h.reader(c), book, expand)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error expanding book"})
+		return
+	}
+	if similar, ok := expanded["similar"].([]Book); ok {
+		h.presentBooks(c, similar)
+	}
+	h.presentBook(c, &book)
+	render(c, http.StatusOK, expandedBook{Book: book, Expanded: expanded})
+}
+
+// Add a new book
+func (h *BookHandler) addBook(c *gin.Context) {
+	var newBook Book
+	if !bindBook(c, &newBook) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	result, ok := h.insertBook(ctx, c, &newBook)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"insertedID": result})
+}
+
+// insertBook stamps and stores a validated new book, filling in its ID and
+// announcing it. On failure it writes the error response and reports false.
+func (h *BookHandler) insertBook(ctx context.Context, c *gin.Context, newBook *Book) (*mongo.InsertOneResult, bool) {
+	createdAt := h.now().UTC()
+	newBook.CreatedAt = &createdAt
+
+	var result *mongo.InsertOneResult
+	var err error
+	if h.config.MaxBooksPerAuthor > 0 {
+		result, err = h.insertUnderAuthorCap(ctx, *newBook)
+	} else {
+		result, err = h.collection.InsertOne(ctx, newBook)
+	}
+	if errors.Is(err, errAuthorCapReached) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Author has reached the book limit", "limit": h.config.MaxBooksPerAuthor})
+		return nil, false
+	}
+	if h.conflictReply(c, err) {
+		return nil, false
+	}
+	if isWriteConflict(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another book by this author is being added, try again"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting book"})
+		return nil, false
+	}
+
+	newBook.ID = result.InsertedID.(primitive.ObjectID)
+	h.bumpGeneration(ctx)
+	h.webhooks.notify(newWebhookEvent(eventBookCreated, newBook.ID, nil, newBook, createdAt))
+	return result, true
+}
+
+// Update a book by ID
+func (h *BookHandler) updateBook(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var updatedBook Book
+	if !bindBook(c, &updatedBook) {
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" && h.config.RequireIfMatch {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// The current state is needed for the If-Match check and to tell whether
+	// the price changed.
+	filter := bson.D{{Key: "_id", Value: objID}, {Key: "deletedAt", Value: notDeleted}}
+	var current bson.Raw
+	err = h.collection.FindOne(ctx, filter).Decode(&current)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
+		}
+		return
+	}
+	var currentBook Book
+	if err == nil {
+		err = bson.Unmarshal(current, &currentBook)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
+		return
+	}
+
+	if ifMatch != "" {
+		etag, err := bookETag(currentBook)
+		if err == nil {
+			filter, err = unchangedFilter(current)
+			filter = append(filter, bson.E{Key: "deletedAt", Value: notDeleted})
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
+			return
+		}
+		if !etagMatches(ifMatch, etag) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Book has been modified"})
+			return
+		}
+	}
+
+	var book Book
+	err = h.writeUnderAuthorCap(ctx, currentBook.Author, updatedBook.Author, func(ctx context.Context) error {
+		return h.collection.FindOneAndUpdate(
+			ctx,
+			filter,
+			bson.D{{Key: "$set", Value: updatedBook}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&book)
+	})
+
+	if h.authorCapReply(c, err) || h.conflictReply(c, err) {
+		return
+	}
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if ifMatch != "" {
+			// The book changed between the ETag check and the write.
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Book has been modified"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
+		return
+	}
+
+	h.recordPriceChange(ctx, book.ID, currentBook.Price, book.Price)
+	h.bumpGeneration(ctx)
+	h.notifyChange(eventBookUpdated, book.ID, &currentBook, &book, h.now().UTC())
+
+	if etag, err := bookETag(book); err == nil {
+		c.Header("ETag", etag)
+	}
+
+	h.scopeBook(c, &book)
+	c.JSON(http.StatusOK, book)
+}
+
+// Get the books on one shelf, sorted by title
+func (h *BookHandler) getBooksOnShelf(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	books, err := h.findBooksIn(
+		ctx,
+		h.reader(c),
+		bson.M{"location.shelf": c.Param("shelf"), "deletedAt": notDeleted},
+		options.Find().SetSort(bson.D{{Key: "title", Value: 1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+
+	h.renderBooks(c, http.StatusOK, books)
+}
+
+type reorderRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// Give the listed books positions 1..n in the order given
+func (h *BookHandler) reorderBooks(c *gin.Context) {
+	var req reorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids must not be empty"})
+		return
+	}
+
+	seen := make(map[primitive.ObjectID]bool, len(req.IDs))
+	models := make([]mongo.WriteModel, 0, len(req.IDs))
+	for i, id := range req.IDs {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID", "id": id})
+			return
+		}
+		if seen[objID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate ID", "id": id})
+			return
+		}
+		seen[objID] = true
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": objID, "deletedAt": notDeleted}).
+			SetUpdate(bson.D{{Key: "$set", Value: bson.M{"position": i + 1}}}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	result, err := h.collection.BulkWrite(ctx, models)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reordering books"})
+		return
+	}
+	if result.ModifiedCount > 0 {
+		h.bumpGeneration(ctx)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"matched": result.MatchedCount, "modified": result.ModifiedCount})
+}
+
+// Mark a book as out of print
+func (h *BookHandler) discontinueBook(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	result, err := h.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objID, "deletedAt": notDeleted},
+		bson.D{{Key: "$set", Value: bson.M{"outOfPrint": true}}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
+		}
+		return
+	}
+	if result.ModifiedCount > 0 {
+		h.bumpGeneration(ctx)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Book discontinued"})
+}
+
+// Delete a book by ID
+func (h *BookHandler) deleteBook(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// The deleted book is kept for the webhook event.
+	var deleted Book
+	deletedAt := h.now().UTC()
+	if h.config.SoftDeleteRetention > 0 {
+		// The book stays hidden until the deletedAt TTL index expires it.
+		err = h.collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": objID, "deletedAt": notDeleted},
+			bson.D{{Key: "$set", Value: bson.M{"deletedAt": deletedAt}}},
+		).Decode(&deleted)
+	} else {
+		err = h.collection.FindOneAndDelete(ctx, bson.M{"_id": objID}).Decode(&deleted)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
+		}
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting book"})
+		return
+	}
+
+	h.recordTombstone(ctx, objID, deletedAt)
+	h.bumpGeneration(ctx)
+	h.webhooks.notify(newWebhookEvent(eventBookDeleted, objID, &deleted, nil, deletedAt))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Book deleted"})
+}
+
+// bindBook decodes the request body into book. Unparseable JSON is a 400, while
+// well-formed JSON that breaks the book rules is a 422 listing each field error.
+func bindBook(c *gin.Context, book *Book) bool {
+	if err := c.ShouldBindJSON(book); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	book.ISBN = normalizeISBN(book.ISBN)
+	book.Author = normalizeAuthor(book.Author)
+	book.Genre = normalizeGenre(book.Genre)
+	redactBook(c, book)
+	book.Reviews, book.AverageRating, book.ReviewCount = nil, 0, 0
+	book.DeletedAt, book.Position = nil, 0
+	book.CreatedAt = nil
+	if errs := book.validate(); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": errs})
+		return false
+	}
+	return true
+}
+
+// conflictReply answers 409 when err is a unique-index violation, naming the
+// fields configured as UNIQUE_ON. It reports whether it wrote a response.
+func (h *BookHandler) conflictReply(c *gin.Context, err error) bool {
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "A book with the same values already exists", "fields": h.config.UniqueOn})
+	return true
+}
+
+// inTransaction runs fn inside one transaction and commits only if it succeeds.
+// Unlike session.WithTransaction it never retries fn, so fn may consume a stream.
+func (h *BookHandler) inTransaction(ctx context.Context, fn func(mongo.SessionContext) error) error {
+	session, err := h.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	return mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
+		if err := session.StartTransaction(); err != nil {
+			return err
+		}
+		if err := fn(sc); err != nil {
+			session.AbortTransaction(context.Background())
+			return err
+		}
+		return session.CommitTransaction(sc)
+	})
+}
+
+// findBooks runs a find and decodes every match, returning an empty slice
+// rather than nil when nothing matched.
+func (h *BookHandler) findBooks(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]Book, error) {
+	return h.findBooksIn(ctx, h.collection, filter, opts...)
+}
+
+// findBooksIn is findBooks against a given collection, such as a reader.
+func (h *BookHandler) findBooksIn(ctx context.Context, collection *mongo.Collection, filter interface{}, opts ...*options.FindOptions) ([]Book, error) {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	books := make([]Book, 0)
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
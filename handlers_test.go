@@ -0,0 +1,278 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGetBooksListsTheInjectedCollection(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99},
+		Book{Title: "Emma", Author: "Jane Austen", Price: 4.5},
+	)
+
+	w := serve(router, http.MethodGet, "/books?sort=title", nil)
+	expectStatus(t, w, http.StatusOK)
+	var books []Book
+	decodeBody(t, w, &books)
+	if got, want := titles(books), []string{"Dune", "Emma"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("titles = %v, want %v", got, want)
+	}
+}
+
+func TestGetBooksEmptyCollection(t *testing.T) {
+	_, router := newTestHandler(t)
+
+	w := serve(router, http.MethodGet, "/books", nil)
+	expectStatus(t, w, http.StatusOK)
+	if w.Body.String() != "[]" {
+		t.Fatalf("body = %s, want []", w.Body.String())
+	}
+}
+
+func TestSoftDeletedBooksAreLeftAlone(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.SoftDeleteRetention = time.Hour })
+	published := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Stock: 5, PublishedAt: &published},
+		Book{Title: "Emma", Author: "Jane Austen", Stock: 5},
+	)
+	expectStatus(t, serve(router, http.MethodDelete, "/books/"+ids[0].Hex(), nil), http.StatusOK)
+
+	dune := "/books/" + ids[0].Hex()
+	expectStatus(t, serve(router, http.MethodPost, dune+"/restock", quantityRequest{Quantity: 1}), http.StatusNotFound)
+	expectStatus(t, serve(router, http.MethodPost, dune+"/reviews", Review{Reviewer: "ann", Rating: 5}), http.StatusNotFound)
+	if got := findBook(t, h, ids[0]); got.Stock != 5 || len(got.Reviews) != 0 {
+		t.Fatalf("deleted book was modified: %+v", got)
+	}
+
+	w := serve(router, http.MethodGet, "/books/by-decade", nil)
+	expectStatus(t, w, http.StatusOK)
+	var decades []decadeCount
+	decodeBody(t, w, &decades)
+	if want := []decadeCount{{Decade: "unknown", Count: 1}}; !reflect.DeepEqual(decades, want) {
+		t.Fatalf("decades = %v, want %v", decades, want)
+	}
+}
+
+func TestAddBookParseErrorIs400ValidationIs422(t *testing.T) {
+	h, router := newTestHandler(t)
+
+	w := serve(router, http.MethodPost, "/books", `{"title": "Dune",`)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = serve(router, http.MethodPost, "/books", `{"title": " ", "author": "Frank Herbert", "price": -1}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+	var body struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	decodeBody(t, w, &body)
+	want := []FieldError{
+		{Field: "title", Message: "is required"},
+		{Field: "price", Message: "must not be negative"},
+	}
+	if body.Error != "Validation failed" || !reflect.DeepEqual(body.Fields, want) {
+		t.Fatalf("body = %+v, want the title and price errors", body)
+	}
+
+	if n, err := h.collection.CountDocuments(testContext(t), bson.M{}); err != nil || n != 0 {
+		t.Fatalf("count = %d (%v), want nothing stored", n, err)
+	}
+}
+
+func TestConcurrentGetBookByIDShareOneQuery(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert"})
+	router, commands := watchCommands(t, h)
+	// Keep the first query in flight long enough for every request to join it.
+	commands.hold = func(bson.Raw) { time.Sleep(200 * time.Millisecond) }
+
+	const requests = 20
+	var wg sync.WaitGroup
+	codes := make([]int, requests)
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = serve(router, http.MethodGet, "/books/"+ids[0].Hex(), nil).Code
+		}()
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i, code)
+		}
+	}
+	if finds := bookFinds(h, commands); len(finds) != 1 {
+		t.Fatalf("got %d finds for %d concurrent requests, want 1", len(finds), requests)
+	}
+}
+
+func TestDiscontinuedBooksAreHiddenUnlessIncluded(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+	)
+
+	expectStatus(t, serve(router, http.MethodPost, "/books/"+ids[1].Hex()+"/discontinue", nil), http.StatusOK)
+	if !findBook(t, h, ids[1]).OutOfPrint {
+		t.Fatal("Emma is not marked out of print")
+	}
+
+	for _, tt := range []struct {
+		path string
+		want []string
+	}{
+		{"/books?sort=title", []string{"Dune"}},
+		{"/books?sort=title&include_out_of_print=true", []string{"Dune", "Emma"}},
+		{"/books/out-of-print", []string{"Emma"}},
+	} {
+		w := serve(router, http.MethodGet, tt.path, nil)
+		expectStatus(t, w, http.StatusOK)
+		var books []Book
+		decodeBody(t, w, &books)
+		if got := titles(books); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateBookReturnsTheUpdatedBook(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99})
+
+	w := serve(router, http.MethodPut, "/books/"+ids[0].Hex(),
+		`{"title": "Dune Messiah", "author": "Frank Herbert", "price": 12.5}`)
+	expectStatus(t, w, http.StatusOK)
+	var book Book
+	decodeBody(t, w, &book)
+	if book.ID != ids[0] || book.Title != "Dune Messiah" || book.Price != 12.5 {
+		t.Fatalf("response = %+v, want the post-update book", book)
+	}
+
+	w = serve(router, http.MethodPut, "/books/"+primitive.NewObjectID().Hex(),
+		`{"title": "Dune", "author": "Frank Herbert"}`)
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestBooksAreListedByShelf(t *testing.T) {
+	_, router := newTestHandler(t)
+	for _, body := range []string{
+		`{"title": "Hyperion", "author": "Dan Simmons", "location": {"shelf": "B2", "row": 3}}`,
+		`{"title": "Dune", "author": "Frank Herbert", "location": {"shelf": "B2", "row": 1}}`,
+		`{"title": "Emma", "author": "Jane Austen", "location": {"shelf": "C1"}}`,
+	} {
+		expectStatus(t, serve(router, http.MethodPost, "/books", body), http.StatusCreated)
+	}
+
+	w := serve(router, http.MethodGet, "/books/shelf/B2", nil)
+	expectStatus(t, w, http.StatusOK)
+	var books []Book
+	decodeBody(t, w, &books)
+	if got, want := titles(books), []string{"Dune", "Hyperion"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("shelf B2 = %v, want %v", got, want)
+	}
+	if loc := books[0].Location; loc == nil || *loc != (Location{Shelf: "B2", Row: 1}) {
+		t.Fatalf("Dune location = %+v, want row 1", books[0].Location)
+	}
+
+	w = serve(router, http.MethodGet, "/books?shelf=C1", nil)
+	expectStatus(t, w, http.StatusOK)
+	books = nil
+	decodeBody(t, w, &books)
+	if got, want := titles(books), []string{"Emma"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("?shelf=C1 = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateBookIfMatch(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.RequireIfMatch = true })
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99})
+	path := "/books/" + ids[0].Hex()
+
+	w := serve(router, http.MethodGet, path, nil)
+	expectStatus(t, w, http.StatusOK)
+	stale := w.Header().Get("ETag")
+	if stale == "" {
+		t.Fatal("GET returned no ETag")
+	}
+
+	w = serve(router, http.MethodPut, path, `{"title": "Dune", "author": "Frank Herbert", "price": 12}`)
+	expectStatus(t, w, http.StatusPreconditionRequired)
+
+	w = serve(router, http.MethodPut, path, `{"title": "Dune", "author": "Frank Herbert", "price": 12}`, "If-Match", stale)
+	expectStatus(t, w, http.StatusOK)
+	if w.Header().Get("ETag") == stale {
+		t.Fatal("ETag did not change with the book")
+	}
+
+	// A second writer still holding the old ETag must not overwrite the first.
+	w = serve(router, http.MethodPut, path, `{"title": "Dune", "author": "Frank Herbert", "price": 15}`, "If-Match", stale)
+	expectStatus(t, w, http.StatusPreconditionFailed)
+	if book := findBook(t, h, ids[0]); book.Price != 12 {
+		t.Fatalf("price = %v, want the first update's 12", book.Price)
+	}
+}
+
+func TestReorderAssignsPositionsInListOrder(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+		Book{Title: "Hyperion", Author: "Dan Simmons"},
+		Book{Title: "Ulysses", Author: "James Joyce", Position: 9},
+	)
+
+	body := map[string][]string{"ids": {ids[2].Hex(), ids[0].Hex(), ids[1].Hex()}}
+	w := serve(router, http.MethodPost, "/books/reorder", body)
+	expectStatus(t, w, http.StatusOK)
+
+	for i, want := range []int{2, 3, 1, 9} {
+		if got := findBook(t, h, ids[i]).Position; got != want {
+			t.Errorf("book %d position = %d, want %d", i, got, want)
+		}
+	}
+
+	w = serve(router, http.MethodGet, "/books?sort=position", nil)
+	expectStatus(t, w, http.StatusOK)
+	var books []Book
+	decodeBody(t, w, &books)
+	if got, want := titles(books), []string{"Hyperion", "Dune", "Emma", "Ulysses"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sort=position = %v, want %v", got, want)
+	}
+}
+
+func TestBatchSumsWeightOfFoundBooks(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", WeightGrams: 450},
+		Book{Title: "Emma", Author: "Jane Austen", WeightGrams: 300},
+		Book{Title: "Ulysses", Author: "James Joyce", WeightGrams: 900},
+	)
+	missing := primitive.NewObjectID()
+
+	path := "/books/batch?total_weight=true&ids=" + ids[0].Hex() + "," + ids[1].Hex() + "," + missing.Hex()
+	w := serve(router, http.MethodGet, path, nil)
+	expectStatus(t, w, http.StatusOK)
+	var body struct {
+		Books            []Book `json:"books"`
+		TotalWeightGrams int    `json:"totalWeightGrams"`
+	}
+	decodeBody(t, w, &body)
+	if len(body.Books) != 2 || body.TotalWeightGrams != 750 {
+		t.Fatalf("got %d books weighing %dg, want 2 weighing 750g", len(body.Books), body.TotalWeightGrams)
+	}
+
+	w = serve(router, http.MethodPost, "/books", `{"title": "Heavy", "author": "A", "weightGrams": -1}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// importBatchSize is how many rows are inserted at a time; in progress mode
+// a line is written after each batch.
+const importBatchSize = 200
+
+// importColumns are the CSV header names understood by importBooksCSV, each
+// with how it sets the book. Tags are separated by semicolons.
+var importColumns = map[string]func(book *Book, value string) error{
+	"title":  func(b *Book, v string) error { b.Title = v; return nil },
+	"author": func(b *Book, v string) error { b.Author = v; return nil },
+	"isbn":   func(b *Book, v string) error { b.ISBN = v; return nil },
+	"genre":  func(b *Book, v string) error { b.Genre = v; return nil },
+	"price": func(b *Book, v string) (err error) {
+		b.Price, err = strconv.ParseFloat(v, 64)
+		return err
+	},
+	"stock": func(b *Book, v string) (err error) {
+		b.Stock, err = strconv.Atoi(v)
+		return err
+	},
+	"publishedAt": func(b *Book, v string) error {
+		t, err := time.Parse("2006-01-02", v)
+		b.PublishedAt = &t
+		return err
+	},
+	"tags": func(b *Book, v string) error {
+		b.Tags = splitQueryList([]string{strings.ReplaceAll(v, ";", ",")})
+		return nil
+	},
+}
+
+type importProgress struct {
+	Rows     int64    `json:"rows"`
+	Inserted int64    `json:"inserted"`
+	Failed   int64    `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+	Done     bool     `json:"done,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func (p *importProgress) fail(format string, args ...interface{}) {
+	p.Failed++
+	if len(p.Errors) < maxRestoreErrors {
+		p.Errors = append(p.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// csvError reports input that is not valid CSV; the import stops there.
+type csvError struct{ err error }
+
+func (e csvError) Error() string { return "csv: " + e.err.Error() }
+
+// Import books from a CSV upload with a header row. Invalid rows are skipped
+// and counted. With ?progress=true the response is NDJSON: a progress line
+// after every batch, then a final line with "done" or "error".
+func (h *BookHandler) importBooksCSV(c *gin.Context) {
+	streaming := false
+	if raw := c.Query("progress"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "progress must be a boolean"})
+			return
+		}
+		streaming = b
+	}
+
+	r := csv.NewReader(c.Request.Body)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV header row is required"})
+		return
+	}
+	setters := make([]func(*Book, string) error, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		setter, ok := importColumns[name]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown column %q", name)})
+			return
+		}
+		setters[i], seen[name] = setter, true
+	}
+	if !seen["title"] || !seen["author"] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "columns title and author are required"})
+		return
+	}
+
+	var report func(importProgress)
+	if streaming {
+		// Progress is written while the body is still being read, which
+		// HTTP/1.x only allows once full duplex is enabled.
+		if err := http.NewResponseController(c.Writer).EnableFullDuplex(); err != nil {
+			log.Printf("import: full duplex: %v", err)
+		}
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		report = func(p importProgress) {
+			if err := enc.Encode(p); err != nil {
+				log.Printf("import: write: %v", err)
+			}
+			c.Writer.Flush()
+		}
+	}
+
+	var progress importProgress
+	err = h.importRows(c.Request.Context(), r, setters, &progress, report)
+
+	var parseErr csvError
+	switch {
+	case streaming && err != nil:
+		progress.Error = err.Error()
+		if !errors.As(err, &parseErr) {
+			log.Printf("import: %v", err)
+			progress.Error = "Error importing books"
+		}
+		report(progress)
+	case streaming:
+		progress.Done = true
+		report(progress)
+	case errors.As(err, &parseErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": parseErr.Error(), "result": progress})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error importing books", "result": progress})
+	default:
+		progress.Done = true
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// importRow is a parsed CSV row waiting in a batch; row is its line number.
+type importRow struct {
+	row  int
+	book Book
+}
+
+// maxImportConflicts bounds how often a batch is retried after losing a race
+// with a concurrent insert by the same author.
+const maxImportConflicts = 3
+
+// importRows reads the CSV body a batch at a time, inserting the valid rows
+// and calling report, when set, after each full batch.
+func (h *BookHandler) importRows(ctx context.Context, r *csv.Reader, setters []func(*Book, string) error, progress *importProgress, report func(importProgress)) error {
+	batch := make([]importRow, 0, importBatchSize)
+	insert := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		var inserted []Book
+		var err error
+		if h.config.MaxBooksPerAuthor > 0 {
+			inserted, err = h.insertBatchUnderAuthorCap(ctx, batch, progress)
+		} else {
+			inserted, err = h.insertBatch(ctx, batch, progress)
+		}
+		batch = batch[:0]
+		if len(inserted) > 0 {
+			progress.Inserted += int64(len(inserted))
+			h.bumpGeneration(ctx)
+		}
+		for i := range inserted {
+			h.webhooks.notify(newWebhookEvent(eventBookCreated, inserted[i].ID, nil, &inserted[i], *inserted[i].CreatedAt))
+		}
+		return err
+	}
+
+	for row := 2; ; row++ { // row 1 is the header
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return csvError{err}
+		}
+		progress.Rows++
+
+		book, err := importBook(record, setters)
+		if err != nil {
+			progress.fail("row %d: %v", row, err)
+			continue
+		}
+		createdAt := h.now().UTC()
+		book.ID, book.CreatedAt = primitive.NewObjectID(), &createdAt
+		batch = append(batch, importRow{row: row, book: book})
+
+		if len(batch) == importBatchSize {
+			if err := insert(); err != nil {
+				return err
+			}
+			if report != nil {
+				report(*progress)
+			}
+		}
+	}
+	// The caller reports the final counts.
+	return insert()
+}
+
+// insertBatch inserts the rows of a batch independently, counting each row
+// the database refuses as failed, and returns the books inserted.
+func (h *BookHandler) insertBatch(ctx context.Context, rows []importRow, progress *importProgress) ([]Book, error) {
+	docs := make([]interface{}, len(rows))
+	for i, r := range rows {
+		docs[i] = r.book
+	}
+	_, err := h.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	failed := make(map[int]bool)
+	var bwe mongo.BulkWriteException
+	if errors.As(err, &bwe) && bwe.WriteConcernError == nil {
+		for _, we := range bwe.WriteErrors {
+			failed[we.Index] = true
+			progress.fail("row %d: %s", rows[we.Index].row, we.Message)
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := make([]Book, 0, len(rows)-len(failed))
+	for i, r := range rows {
+		if !failed[i] {
+			inserted = append(inserted, r.book)
+		}
+	}
+	return inserted, nil
+}
+
+// insertBatchUnderAuthorCap inserts the rows of a batch in one transaction,
+// skipping the books whose author has reached MaxBooksPerAuthor as addBook
+// does. A row the database refuses aborts the transaction, so it is counted
+// as failed and the rest of the batch is tried again without it.
+func (h *BookHandler) insertBatchUnderAuthorCap(ctx context.Context, rows []importRow, progress *importProgress) ([]Book, error) {
+	pending := append([]importRow(nil), rows...)
+	for conflicts := 0; ; {
+		var admitted, refused []importRow
+		err := h.inTransaction(ctx, func(sc mongo.SessionContext) error {
+			books := make([]Book, len(pending))
+			for i, r := range pending {
+				books[i] = r.book
+			}
+			admit, err := h.admitUnderAuthorCap(sc, books)
+			if err != nil {
+				return err
+			}
+			admitted, refused = admitted[:0], refused[:0]
+			docs := make([]interface{}, 0, len(pending))
+			for i, r := range pending {
+				if admit[i] {
+					admitted = append(admitted, r)
+					docs = append(docs, r.book)
+				} else {
+					refused = append(refused, r)
+				}
+			}
+			if len(docs) == 0 {
+				return nil
+			}
+			_, err = h.collection.InsertMany(sc, docs)
+			return err
+		})
+
+		var bwe mongo.BulkWriteException
+		switch {
+		case errors.As(err, &bwe) && bwe.WriteConcernError == nil && len(bwe.WriteErrors) > 0:
+			bad := admitted[bwe.WriteErrors[0].Index]
+			progress.fail("row %d: %s", bad.row, bwe.WriteErrors[0].Message)
+			for i, r := range pending {
+				if r.row == bad.row {
+					pending = append(pending[:i], pending[i+1:]...)
+					break
+				}
+			}
+			continue
+		case isWriteConflict(err) && conflicts < maxImportConflicts:
+			conflicts++
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		for _, r := range refused {
+			progress.fail("row %d: %v", r.row, errAuthorCapReached)
+		}
+		inserted := make([]Book, len(admitted))
+		for i, r := range admitted {
+			inserted[i] = r.book
+		}
+		return inserted, nil
+	}
+}
+
+// importBook builds and validates a book from one CSV record.
+func importBook(record []string, setters []func(*Book, string) error) (Book, error) {
+	var book Book
+	for i, value := range record {
+		if i >= len(setters) {
+			return book, fmt.Errorf("has more fields than the header")
+		}
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if err := setters[i](&book, value); err != nil {
+			return book, fmt.Errorf("column %d: %v", i+1, err)
+		}
+	}
+	book.ISBN = normalizeISBN(book.ISBN)
+	book.Author = normalizeAuthor(book.Author)
+	book.Genre = normalizeGenre(book.Genre)
+	if errs := book.validate(); len(errs) > 0 {
+		return book, fmt.Errorf("%s %s", errs[0].Field, errs[0].Message)
+	}
+	return book, nil
+}
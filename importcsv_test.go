@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportReportsProgressAfterEachBatch(t *testing.T) {
+	_, router := newTestHandler(t)
+	var csv strings.Builder
+	csv.WriteString("title,author,price\n")
+	for i := 1; i <= 2*importBatchSize+50; i++ {
+		fmt.Fprintf(&csv, "Book %d,Ann Author,%d\n", i, i)
+		if i == importBatchSize+50 {
+			csv.WriteString("Broken,Ann Author,not-a-price\n")
+		}
+	}
+
+	w := serve(router, http.MethodPost, "/books/import?progress=true", csv.String())
+	expectStatus(t, w, http.StatusOK)
+	var lines []importProgress
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var p importProgress
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		p.Errors = nil
+		lines = append(lines, p)
+	}
+	want := []importProgress{
+		{Rows: importBatchSize, Inserted: importBatchSize},
+		{Rows: 2*importBatchSize + 1, Inserted: 2 * importBatchSize, Failed: 1},
+		{Rows: 2*importBatchSize + 51, Inserted: 2*importBatchSize + 50, Failed: 1, Done: true},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("progress = %+v, want %+v", lines, want)
+	}
+}
+
+func TestImportAppliesAuthorCapAndSendsWebhooks(t *testing.T) {
+	requireReplicaSet(t)
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	h, router := newTestHandler(t, func(cfg *Config) {
+		cfg.MaxBooksPerAuthor = 2
+		cfg.WebhookURLs = []string{server.URL}
+	})
+	insertBooks(t, h, Book{Title: "Existing", Author: "Ann Author"})
+
+	csv := "title,author\nFirst,Ann Author\nSecond,Ann Author\nThird,Ann Author\nOther,Bob Writer\n"
+	w := serve(router, http.MethodPost, "/books/import", csv)
+	expectStatus(t, w, http.StatusOK)
+	var progress importProgress
+	decodeBody(t, w, &progress)
+	wantErrors := []string{"row 3: " + errAuthorCapReached.Error(), "row 4: " + errAuthorCapReached.Error()}
+	if progress.Inserted != 2 || progress.Failed != 2 || !reflect.DeepEqual(progress.Errors, wantErrors) {
+		t.Fatalf("progress = %+v, want First and Other inserted and %v", progress, wantErrors)
+	}
+
+	// One book.created per inserted book.
+	receiver.waitFor(t, 2)
+}
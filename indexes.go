@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bookIndexes are the indexes created at startup, keyed by name. They are also
+// the only values accepted as a listing ?hint=.
+var bookIndexes = map[string]bson.D{
+	"title_1":          {{Key: "title", Value: 1}},
+	"author_1":         {{Key: "author", Value: 1}},
+	"price_1":          {{Key: "price", Value: 1}},
+	"isbn_1":           {{Key: "isbn", Value: 1}},
+	"author_1_price_1": {{Key: "author", Value: 1}, {Key: "price", Value: 1}},
+
+	"location.shelf_1_title_1": {{Key: "location.shelf", Value: 1}, {Key: "title", Value: 1}},
+}
+
+// textIndex backs $text searches over titles and authors. It is kept out of
+// bookIndexes because a text index cannot serve as a ?hint=.
+var textIndex = mongo.IndexModel{
+	Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "author", Value: "text"}},
+	Options: options.Index().SetName("title_text_author_text"),
+}
+
+// deletedAtTTLIndex expires soft-deleted books; see Config.SoftDeleteRetention.
+const deletedAtTTLIndex = "deletedAt_ttl"
+
+func ensureIndexes(ctx context.Context, collection *mongo.Collection, cfg Config) error {
+	models := make([]mongo.IndexModel, 0, len(bookIndexes))
+	for name, keys := range bookIndexes {
+		models = append(models, mongo.IndexModel{
+			Keys:    keys,
+			Options: options.Index().SetName(name),
+		})
+	}
+	models = append(models, textIndex)
+	if _, err := collection.Indexes().CreateMany(ctx, models); err != nil {
+		return err
+	}
+
+	if err := ensureUniqueIndex(ctx, collection, cfg.UniqueOn); err != nil {
+		return err
+	}
+
+	if cfg.SoftDeleteRetention > 0 {
+		if err := ensureTTLIndex(ctx, collection, deletedAtTTLIndex, "deletedAt", cfg.SoftDeleteRetention); err != nil {
+			return err
+		}
+	}
+
+	if cfg.TombstoneRetention > 0 {
+		return ensureTTLIndex(ctx, tombstones(collection), tombstoneTTLIndex, "expiresAt", 0)
+	}
+	return nil
+}
+
+// uniqueIndexPrefix names the index built from Config.UniqueOn.
+const uniqueIndexPrefix = "unique_"
+
+// uniqueFields are the fields UNIQUE_ON may combine.
+var uniqueFields = map[string]bool{"isbn": true, "title": true, "author": true}
+
+// ensureUniqueIndex makes the configured fields unique together, dropping any
+// unique index left over from a different UNIQUE_ON. Fields are omitted when
+// empty, so the index only covers books that carry all of them.
+func ensureUniqueIndex(ctx context.Context, collection *mongo.Collection, fields []string) error {
+	name := ""
+	if len(fields) > 0 {
+		name = uniqueIndexPrefix + strings.Join(fields, "_")
+	}
+
+	specs, err := collection.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if strings.HasPrefix(spec.Name, uniqueIndexPrefix) && spec.Name != name {
+			if _, err := collection.Indexes().DropOne(ctx, spec.Name); err != nil {
+				return err
+			}
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	keys := bson.D{}
+	partial := bson.M{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+		partial[field] = bson.M{"$type": "string"}
+	}
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetName(name).SetUnique(true).SetPartialFilterExpression(partial),
+	})
+	return err
+}
+
+// ensureTTLIndex creates a TTL index on field, or adjusts the expiry of an
+// existing one via collMod when the configured retention has changed.
+func ensureTTLIndex(ctx context.Context, collection *mongo.Collection, name, field string, ttl time.Duration) error {
+	seconds := int32(ttl.Seconds())
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetName(name).SetExpireAfterSeconds(seconds),
+	})
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Name != "IndexOptionsConflict" {
+		return err
+	}
+
+	return collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: name},
+			{Key: "expireAfterSeconds", Value: seconds},
+		}},
+	}).Err()
+}
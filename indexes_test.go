@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSoftDeleteRetentionCreatesTTLIndex(t *testing.T) {
+	h, _ := newTestHandler(t, func(cfg *Config) { cfg.SoftDeleteRetention = 90 * time.Minute })
+
+	cursor, err := h.collection.Indexes().List(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []bson.M
+	if err := cursor.All(testContext(t), &indexes); err != nil {
+		t.Fatal(err)
+	}
+	for _, index := range indexes {
+		if index["name"] != deletedAtTTLIndex {
+			continue
+		}
+		if seconds, _ := index["expireAfterSeconds"].(int32); seconds != 90*60 {
+			t.Fatalf("expireAfterSeconds = %v, want %d", index["expireAfterSeconds"], 90*60)
+		}
+		return
+	}
+	t.Fatalf("no %s index among %v", deletedAtTTLIndex, indexes)
+}
+
+func TestUniqueOnTitleAndAuthorRejectsDuplicates(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.UniqueOn = []string{"title", "author"} })
+
+	w := serve(router, http.MethodPost, "/books", `{"title": "Dune", "author": "Frank Herbert", "isbn": "9780441172719"}`)
+	expectStatus(t, w, http.StatusCreated)
+	// Same title by another author, and the same ISBN, are both fine.
+	w = serve(router, http.MethodPost, "/books", `{"title": "Dune", "author": "Brian Herbert", "isbn": "9780441172719"}`)
+	expectStatus(t, w, http.StatusCreated)
+
+	w = serve(router, http.MethodPost, "/books", `{"title": "Dune", "author": "Frank Herbert"}`)
+	expectStatus(t, w, http.StatusConflict)
+	var body struct {
+		Fields []string `json:"fields"`
+	}
+	decodeBody(t, w, &body)
+	if want := []string{"title", "author"}; !reflect.DeepEqual(body.Fields, want) {
+		t.Fatalf("conflicting fields = %v, want %v", body.Fields, want)
+	}
+	if n, err := h.collection.CountDocuments(testContext(t), bson.M{}); err != nil || n != 2 {
+		t.Fatalf("count = %d (%v), want 2", n, err)
+	}
+}
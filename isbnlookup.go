@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errISBNUnknown = errors.New("isbn not known to the lookup service")
+
+// isbnMetadata is what the lookup service returns for GET {base}/isbn/{isbn}.
+type isbnMetadata struct {
+	Title       string   `json:"title"`
+	Authors     []string `json:"authors"`
+	PublishedAt string   `json:"publishedAt"` // YYYY-MM-DD or RFC 3339
+	WordCount   int      `json:"wordCount"`
+	Tags        []string `json:"tags"`
+}
+
+// lookupISBN fetches the metadata for a normalized ISBN. errISBNUnknown means
+// the service answered 404; any other error means it could not be used.
+func (h *BookHandler) lookupISBN(ctx context.Context, isbn string) (isbnMetadata, error) {
+	var meta isbnMetadata
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.config.ISBNLookupURL+"/isbn/"+url.PathEscape(isbn), nil)
+	if err != nil {
+		return meta, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if h.config.ISBNLookupKey != "" {
+		req.Header.Set("X-API-Key", h.config.ISBNLookupKey)
+	}
+
+	resp, err := h.isbnClient.Do(req)
+	if err != nil {
+		return meta, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return meta, errISBNUnknown
+	case resp.StatusCode != http.StatusOK:
+		return meta, fmt.Errorf("lookup service answered %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&meta)
+	return meta, err
+}
+
+// book maps the service's metadata onto a new book.
+func (m isbnMetadata) book(isbn string) Book {
+	book := Book{Title: m.Title, ISBN: isbn, WordCount: m.WordCount, Tags: m.Tags}
+	if len(m.Authors) > 0 {
+		book.Author = normalizeAuthor(m.Authors[0])
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, m.PublishedAt); err == nil {
+			t = t.UTC()
+			book.PublishedAt = &t
+			break
+		}
+	}
+	return book
+}
+
+// Create a book from the metadata the lookup service holds for {"isbn": ...}
+func (h *BookHandler) importByISBN(c *gin.Context) {
+	if h.config.ISBNLookupURL == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "ISBN lookup is not configured"})
+		return
+	}
+	var req struct {
+		ISBN string `json:"isbn"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	isbn := normalizeISBN(req.ISBN)
+	if !validISBN(isbn) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Validation failed",
+			"fields": []FieldError{{Field: "isbn", Message: "must be a 10 or 13 digit ISBN"}},
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	meta, err := h.lookupISBN(ctx, isbn)
+	if errors.Is(err, errISBNUnknown) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ISBN not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "ISBN lookup failed"})
+		return
+	}
+
+	book := meta.book(isbn)
+	if errs := book.validate(); len(errs) > 0 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "ISBN lookup returned incomplete metadata", "fields": errs})
+		return
+	}
+	if _, ok := h.insertBook(ctx, c, &book); !ok {
+		return
+	}
+
+	h.renderBook(c, http.StatusCreated, book)
+}
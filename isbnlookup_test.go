@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newLookupStub serves metadata for known ISBNs and 404 for the rest. An
+// ISBN mapped to nil answers 500.
+func newLookupStub(t *testing.T, key string, known map[string]*isbnMetadata) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /isbn/{isbn}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != key {
+			http.Error(w, "bad key", http.StatusUnauthorized)
+			return
+		}
+		meta, ok := known[r.PathValue("isbn")]
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case meta == nil:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			json.NewEncoder(w).Encode(meta)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestImportByISBNCreatesTheBookFromTheLookup(t *testing.T) {
+	stub := newLookupStub(t, "secret", map[string]*isbnMetadata{
+		"9780441172719": {
+			Title:       "Dune",
+			Authors:     []string{"  Frank   Herbert "},
+			PublishedAt: "1965-08-01",
+			Tags:        []string{"scifi"},
+		},
+		"9780141439518": nil,
+	})
+	h, router := newTestHandler(t, func(cfg *Config) {
+		cfg.ISBNLookupURL = stub.URL
+		cfg.ISBNLookupKey = "secret"
+	})
+
+	w := serve(router, http.MethodPost, "/books/import-by-isbn", `{"isbn": "978-0-441-17271-9"}`)
+	expectStatus(t, w, http.StatusCreated)
+	var created Book
+	decodeBody(t, w, &created)
+	stored := findBook(t, h, created.ID)
+	published := time.Date(1965, time.August, 1, 0, 0, 0, 0, time.UTC)
+	if stored.Title != "Dune" || stored.Author != "Frank Herbert" || stored.ISBN != "9780441172719" ||
+		stored.PublishedAt == nil || !stored.PublishedAt.Equal(published) || !reflect.DeepEqual(stored.Tags, []string{"scifi"}) {
+		t.Fatalf("stored %+v, want Dune from the lookup", stored)
+	}
+
+	for _, tt := range []struct {
+		isbn string
+		want int
+	}{
+		{"9780140449136", http.StatusNotFound},
+		{"9780141439518", http.StatusBadGateway},
+		{"12345", http.StatusUnprocessableEntity},
+	} {
+		w := serve(router, http.MethodPost, "/books/import-by-isbn", map[string]string{"isbn": tt.isbn})
+		expectStatus(t, w, tt.want)
+	}
+}
+
+func TestImportByISBNServiceUnavailable(t *testing.T) {
+	stub := newLookupStub(t, "", nil)
+	stub.Close()
+	_, router := newTestHandler(t, func(cfg *Config) { cfg.ISBNLookupURL = stub.URL })
+
+	w := serve(router, http.MethodPost, "/books/import-by-isbn", `{"isbn": "9780441172719"}`)
+	expectStatus(t, w, http.StatusBadGateway)
+}
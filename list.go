@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sortOrders maps the accepted ?sort= values to their sort documents.
+var sortOrders = map[string]bson.D{
+	// Unreviewed books have no averageRating, so they fall to the end.
+	"rating": {{Key: "averageRating", Value: -1}, {Key: "reviewCount", Value: -1}},
+	// Manual order from /books/reorder; books never placed sort first.
+	"position": {{Key: "position", Value: 1}},
+	"newest":   {{Key: "createdAt", Value: -1}},
+	"title":    {{Key: "title", Value: 1}},
+}
+
+// listParams are the query parameters listFilter understands, and so the
+// only ones a saved filter may hold, less the admin-only ?hint=.
+var listParams = map[string]bool{
+	"sort":                 true,
+	"search":               true,
+	"tier":                 true,
+	"shelf":                true,
+	"min_reviews":          true,
+	"max_reading_minutes":  true,
+	"include_out_of_print": true,
+}
+
+// listQuery turns the listing query parameters into a filter and find options,
+// starting from the saved filter named by ?saved_filter= if any. An error
+// means the parameters were invalid and the caller should answer 400.
+func (h *BookHandler) listQuery(c *gin.Context) (bson.M, *options.FindOptions, error) {
+	query, err := h.listValues(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h.listFilter(query)
+}
+
+// listValues is the request's query merged over the saved filter named by
+// ?saved_filter=, if any.
+func (h *BookHandler) listValues(c *gin.Context) (url.Values, error) {
+	query := c.Request.URL.Query()
+	if name := query.Get("saved_filter"); name != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+		defer cancel()
+
+		saved, err := h.savedFilter(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		// Parameters given on the request override the saved ones.
+		for param, value := range saved.Params {
+			if !listParams[param] {
+				return nil, fmt.Errorf("saved filter %q uses unsupported parameter %q", name, param)
+			}
+			if _, ok := query[param]; !ok {
+				query.Set(param, value)
+			}
+		}
+	}
+	// ?hint= steers the query planner, which is for admins tuning the
+	// catalog; from anyone else it is ignored.
+	if !isAdmin(c) {
+		query.Del("hint")
+	}
+	return query, nil
+}
+
+// listFilter builds the listing filter and find options from query values.
+func (h *BookHandler) listFilter(query url.Values) (bson.M, *options.FindOptions, error) {
+	filter := bson.M{"deletedAt": notDeleted}
+	opts := options.Find()
+	// Alternatives ($or) from different parameters must all hold, so each
+	// becomes one clause of a top-level $and.
+	var and bson.A
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = h.config.DefaultSort
+	}
+	if sortBy != "" {
+		order, ok := sortOrders[sortBy]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown sort %q", sortBy)
+		}
+		opts.SetSort(order)
+	}
+
+	if hint := query.Get("hint"); hint != "" {
+		if _, ok := bookIndexes[hint]; !ok {
+			return nil, nil, fmt.Errorf("unknown index hint %q", hint)
+		}
+		opts.SetHint(hint)
+	}
+
+	// A substring match on title or author; unlike a text search it needs no index.
+	if search := strings.TrimSpace(query.Get("search")); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"title": pattern},
+			bson.M{"author": pattern},
+		}})
+	}
+
+	// ?tier=budget,premium (or repeated ?tier=) matches any of the named tiers.
+	if names := splitQueryList(query["tier"]); len(names) > 0 {
+		ranges := bson.A{}
+		for _, name := range names {
+			tier, ok := h.config.priceTier(name)
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown tier %q", name)
+			}
+			ranges = append(ranges, tier.filter())
+		}
+		and = append(and, bson.M{"$or": ranges})
+	}
+
+	if shelf := query.Get("shelf"); shelf != "" {
+		filter["location.shelf"] = shelf
+	}
+
+	// Counted from the embedded array via $size rather than the stored reviewCount.
+	if raw := query.Get("min_reviews"); raw != "" {
+		minReviews, err := strconv.Atoi(raw)
+		if err != nil || minReviews < 0 {
+			return nil, nil, fmt.Errorf("min_reviews must be a non-negative integer")
+		}
+		filter["$expr"] = bson.M{"$gte": bson.A{
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$reviews", bson.A{}}}},
+			minReviews,
+		}}
+	}
+
+	// Short reads: books whose word count fits in the given reading time.
+	if raw := query.Get("max_reading_minutes"); raw != "" {
+		minutes, err := strconv.Atoi(raw)
+		if err != nil || minutes < 1 {
+			return nil, nil, fmt.Errorf("max_reading_minutes must be a positive integer")
+		}
+		filter["wordCount"] = bson.M{"$gt": 0, "$lte": minutes * h.config.WordsPerMinute}
+	}
+
+	// Discontinued books are hidden unless explicitly asked for.
+	includeOutOfPrint := false
+	if raw := query.Get("include_out_of_print"); raw != "" {
+		include, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("include_out_of_print must be a boolean")
+		}
+		includeOutOfPrint = include
+	}
+	if !includeOutOfPrint {
+		filter["outOfPrint"] = bson.M{"$ne": true}
+	}
+
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+
+	return filter, opts, nil
+}
+
+// splitQueryList flattens repeated and comma-separated query values.
+func splitQueryList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestListingPassesKnownHintAndRejectsUnknown(t *testing.T) {
+	h, _ := newTestHandler(t)
+	insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99})
+	router, commands := watchCommands(t, h)
+
+	commands.take()
+	expectStatus(t, serve(router, http.MethodGet, "/books?hint=author_1_price_1", nil, asAdmin...), http.StatusOK)
+	finds := bookFinds(h, commands)
+	if len(finds) != 1 {
+		t.Fatalf("got %d finds on books, want 1", len(finds))
+	}
+	if hint, _ := finds[0].Lookup("hint").StringValueOK(); hint != "author_1_price_1" {
+		t.Fatalf("find hint = %v, want author_1_price_1", finds[0].Lookup("hint"))
+	}
+
+	// Only admins may hint; anyone else's hint is dropped, valid or not.
+	for _, hint := range []string{"author_1_price_1", "no_such_index"} {
+		expectStatus(t, serve(router, http.MethodGet, "/books?hint="+hint, nil), http.StatusOK)
+		finds = bookFinds(h, commands)
+		if len(finds) != 1 {
+			t.Fatalf("got %d finds on books, want 1", len(finds))
+		}
+		if _, err := finds[0].LookupErr("hint"); err == nil {
+			t.Fatalf("public ?hint=%s reached the find: %v", hint, finds[0])
+		}
+	}
+
+	w := serve(router, http.MethodGet, "/books?hint=no_such_index", nil, asAdmin...)
+	expectStatus(t, w, http.StatusBadRequest)
+	if finds := bookFinds(h, commands); len(finds) != 0 {
+		t.Fatalf("unknown hint still queried: %v", finds)
+	}
+}
+
+// bookFinds takes the recorded find commands on the books collection.
+func bookFinds(h *BookHandler, commands *commandLog) []bson.Raw {
+	var finds []bson.Raw
+	for _, cmd := range commands.take("find") {
+		if coll, _ := cmd.Lookup("find").StringValueOK(); coll == h.collection.Name() {
+			finds = append(finds, cmd)
+		}
+	}
+	return finds
+}
+
+func TestSearchMatchesTitleOrAuthor(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "The Dune Chronicles", Author: "Frank Herbert"},
+		Book{Title: "Sandworms", Author: "Brian Dunedin"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+		Book{Title: "Regex (a+b)*", Author: "Anon"},
+	)
+
+	for _, tt := range []struct {
+		search string
+		want   []string
+	}{
+		// One book matches by title, the other by author, case-insensitively.
+		{"dune", []string{"Sandworms", "The Dune Chronicles"}},
+		// The term is matched literally, not as a pattern.
+		{"(a+b)*", []string{"Regex (a+b)*"}},
+		{"nothing", []string{}},
+	} {
+		w := serve(router, http.MethodGet, "/books?sort=title&search="+url.QueryEscape(tt.search), nil)
+		expectStatus(t, w, http.StatusOK)
+		var books []Book
+		decodeBody(t, w, &books)
+		if got := titles(books); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.search, got, tt.want)
+		}
+	}
+}
+
+func TestMinReviewsFiltersOnReviewCount(t *testing.T) {
+	h, router := newTestHandler(t)
+	reviews := func(n int) []Review {
+		var rs []Review
+		for i := range n {
+			rs = append(rs, Review{Reviewer: fmt.Sprint("r", i), Rating: 4})
+		}
+		return rs
+	}
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Reviews: reviews(3)},
+		Book{Title: "Emma", Author: "Jane Austen", Reviews: reviews(2)},
+		Book{Title: "Hyperion", Author: "Dan Simmons", Reviews: reviews(1)},
+		Book{Title: "Ulysses", Author: "James Joyce"},
+	)
+
+	for _, tt := range []struct {
+		minReviews string
+		want       []string
+	}{
+		{"0", []string{"Dune", "Emma", "Hyperion", "Ulysses"}},
+		{"2", []string{"Dune", "Emma"}},
+		{"3", []string{"Dune"}},
+		{"4", []string{}},
+	} {
+		w := serve(router, http.MethodGet, "/books?sort=title&min_reviews="+tt.minReviews, nil)
+		expectStatus(t, w, http.StatusOK)
+		var books []Book
+		decodeBody(t, w, &books)
+		if got := titles(books); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("min_reviews=%s = %v, want %v", tt.minReviews, got, tt.want)
+		}
+	}
+
+	w := serve(router, http.MethodGet, "/books?min_reviews=-1", nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestRatingSortPutsUnreviewedBooksLast(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "Ulysses", Author: "James Joyce"},
+		Book{Title: "Emma", Author: "Jane Austen", AverageRating: 4.5, ReviewCount: 2},
+		Book{Title: "Hyperion", Author: "Dan Simmons", AverageRating: 3, ReviewCount: 40},
+		Book{Title: "Dune", Author: "Frank Herbert", AverageRating: 4.5, ReviewCount: 10},
+	)
+
+	w := serve(router, http.MethodGet, "/books?sort=rating", nil)
+	expectStatus(t, w, http.StatusOK)
+	var books []Book
+	decodeBody(t, w, &books)
+	// Equal averages fall back to the review count.
+	if got, want := titles(books), []string{"Dune", "Emma", "Hyperion", "Ulysses"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sort=rating = %v, want %v", got, want)
+	}
+
+	w = serve(router, http.MethodGet, "/books/top-rated?limit=2", nil)
+	expectStatus(t, w, http.StatusOK)
+	books = nil
+	decodeBody(t, w, &books)
+	if got, want := titles(books), []string{"Dune", "Emma"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("top-rated?limit=2 = %v, want %v", got, want)
+	}
+}
+
+func TestTiersCombineAsAlternatives(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) {
+		cfg.PriceTiers = []PriceTier{
+			{Name: "budget", Min: 0, Max: 10},
+			{Name: "mid", Min: 10, Max: 25},
+			{Name: "premium", Min: 25},
+		}
+	})
+	insertBooks(t, h,
+		Book{Title: "Budget", Author: "A", Price: 9.99},
+		Book{Title: "Free", Author: "B", Price: 0},
+		Book{Title: "Low mid", Author: "C", Price: 10},
+		Book{Title: "High mid", Author: "D", Price: 24.99},
+		Book{Title: "Premium", Author: "E", Price: 25},
+		Book{Title: "Deluxe", Author: "F", Price: 120},
+	)
+
+	for _, query := range []string{"tier=budget,premium", "tier=budget&tier=premium"} {
+		w := serve(router, http.MethodGet, "/books?sort=title&"+query, nil)
+		expectStatus(t, w, http.StatusOK)
+		var books []Book
+		decodeBody(t, w, &books)
+		// Mid prices, from 10 up to but excluding 25, fall between the two.
+		if got, want := titles(books), []string{"Budget", "Deluxe", "Free", "Premium"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", query, got, want)
+		}
+	}
+
+	w := serve(router, http.MethodGet, "/books?tier=budget,luxury", nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestDefaultSortNewest(t *testing.T) {
+	t.Setenv("DEFAULT_SORT", "newest")
+	h, router := newTestHandler(t)
+	day := func(d int) *time.Time {
+		at := time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC)
+		return &at
+	}
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", CreatedAt: day(2)},
+		Book{Title: "Emma", Author: "Jane Austen", CreatedAt: day(9)},
+		Book{Title: "Hyperion", Author: "Dan Simmons", CreatedAt: day(5)},
+	)
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Emma", "Hyperion", "Dune"}},
+		// An explicit ?sort= still wins over the default.
+		{"?sort=title", []string{"Dune", "Emma", "Hyperion"}},
+	} {
+		w := serve(router, http.MethodGet, "/books"+tt.query, nil)
+		expectStatus(t, w, http.StatusOK)
+		var books []Book
+		decodeBody(t, w, &books)
+		if got := titles(books); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("/books%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -2,34 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type Book struct {
-	ID     primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Title  string             `json:"title" bson:"title"`
-	Author string             `json:"author" bson:"author"`
-	Price  float64            `json:"price" bson:"price"`
-}
-
-var bookCollection *mongo.Collection
-
-func initMongoDB() {
-	clientOptions := options.Client().ApplyURI("mongodb://localhost:27017")
+func initMongoDB(cfg Config) *mongo.Client {
+	clientOptions := options.Client().ApplyURI(cfg.MongoURI)
 	client, err := mongo.NewClient(clientOptions)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.QueryTimeout)
 	defer cancel()
 
 	err = client.Connect(ctx)
@@ -37,155 +30,78 @@ func initMongoDB() {
 		log.Fatal(err)
 	}
 
-	bookCollection = client.Database("library").Collection("books")
+	return client
 }
 
-// Get all books
-func getBooks(c *gin.Context) {
-	var books []Book
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	cursor, err := bookCollection.Find(ctx, bson.M{})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
-		return
-	}
-
-	defer cursor.Close(ctx)
-	for cursor.Next(ctx) {
-		var book Book
-		if err = cursor.Decode(&book); err != nil {
-			log.Fatal(err)
-		}
-		books = append(books, book)
-	}
-
-	c.JSON(http.StatusOK, books)
-}
-
-// Get a single book by ID
-func getBookByID(c *gin.Context) {
-	id := c.Param("id")
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
-	}
-
-	var book Book
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	err = bookCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&book)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, book)
-}
-
-// Add a new book
-func addBook(c *gin.Context) {
-	var newBook Book
-	if err := c.BindJSON(&newBook); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+func main() {
+	cfg := loadConfig()
+	client := initMongoDB(cfg)
+	collection := client.Database(cfg.Database).Collection(cfg.Collection)
 
-	result, err := bookCollection.InsertOne(ctx, newBook)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting book"})
-		return
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.QueryTimeout)
+	if err := ensureIndexes(ctx, collection, cfg); err != nil {
+		log.Fatal(err)
 	}
+	cancel()
 
-	c.JSON(http.StatusCreated, gin.H{"insertedID": result})
-}
+	books := NewBookHandler(collection, cfg)
 
-// Update a book by ID
-func updateBook(c *gin.Context) {
-	id := c.Param("id")
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
-	}
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go books.refreshBestsellersEvery(jobs)
 
-	var updatedBook Book
-	if err := c.BindJSON(&updatedBook); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+	requests := &inFlight{}
+	router := newRouter(books, requests)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Start the server on the configured port (8000 by default)
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
 
-	result, err := bookCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": objID},
-		bson.D{{Key: "$set", Value: updatedBook}},
-	)
+	stop, release := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer release()
+	<-stop.Done()
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
-		return
-	}
+	log.Printf("shutting down, draining for up to %s", cfg.ShutdownTimeout)
+	shutdown(srv, cfg.ShutdownTimeout, requests)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Book updated"})
+	stopJobs()
+	disconnectCtx, cancelDisconnect := context.WithTimeout(context.Background(), cfg.QueryTimeout)
+	defer cancelDisconnect()
+	client.Disconnect(disconnectCtx)
 }
 
-// Delete a book by ID
-func deleteBook(c *gin.Context) {
-	id := c.Param("id")
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// shutdown lets in-flight requests drain for up to timeout, then cuts off the
+// connections still open. It returns how many requests were cut off.
+func shutdown(srv *http.Server, timeout time.Duration, requests *inFlight) int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-
-	result, err := bookCollection.DeleteOne(ctx, bson.M{"_id": objID})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting book"})
-		return
-	}
-
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
-		return
+	if err := srv.Shutdown(ctx); err == nil {
+		return 0
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Book deleted"})
+	n := requests.count()
+	log.Printf("drain timed out with %d request(s) in flight, forcing close", n)
+	srv.Close()
+	return n
 }
 
-func main() {
-	initMongoDB()
-	router := gin.Default()
+// newRouter wires the middleware and every route onto a fresh engine.
+func newRouter(books *BookHandler, requests *inFlight) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Logger(), recovery())
+	router.Use(requests.middleware(), books.authenticate(), books.apiVersion(), books.readConcern())
 	router.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "pong",
 		})
 	})
 	// Define CRUD routes
-	router.GET("/books", getBooks)          // Retrieve all books
-	router.GET("/books/:id", getBookByID)   // Retrieve a specific book by ID
-	router.POST("/books", addBook)          // Add a new book
-	router.PUT("/books/:id", updateBook)    // Update a specific book by ID
-	router.DELETE("/books/:id", deleteBook) // Delete a specific book by ID
-
-	// Start the server on port 8000
-	router.Run(":8000")
+	books.registerRoutes(router)
+	books.registerAdminRoutes(router.Group("/admin"))
+	return router
 }
 
 // install all dependencies using command --->  go get ./...
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The handler tests run against the MongoDB at MONGO_TEST_URI (default
+// mongodb://localhost:27017), each in a database of its own that is dropped
+// afterwards. They are skipped when no server answers.
+
+var (
+	testURI        = "mongodb://localhost:27017"
+	testClient     *mongo.Client
+	testClientErr  error
+	testDatabaseID atomic.Int64
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	if uri := os.Getenv("MONGO_TEST_URI"); uri != "" {
+		testURI = uri
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	testClient, testClientErr = mongo.Connect(ctx, options.Client().ApplyURI(testURI).SetServerSelectionTimeout(2*time.Second))
+	if testClientErr == nil {
+		testClientErr = testClient.Ping(ctx, nil)
+	}
+	cancel()
+
+	code := m.Run()
+	if testClientErr == nil {
+		testClient.Disconnect(context.Background())
+	}
+	os.Exit(code)
+}
+
+// testConfig is the configuration the handler tests start from: the
+// defaults, with settings that would slow tests down turned off.
+func testConfig() Config {
+	cfg := loadConfig()
+	cfg.QueryTimeout = 5 * time.Second
+	cfg.WebhookURLs = nil
+	cfg.AdminToken = testAdminToken
+	return cfg
+}
+
+const testAdminToken = "test-admin-token"
+
+// asAdmin are the headers that authenticate a request as an admin.
+var asAdmin = []string{"Authorization", "Bearer " + testAdminToken}
+
+// newTestHandler returns a handler over a fresh database, with its indexes,
+// and the router serving it. configure adjusts the configuration first.
+func newTestHandler(t *testing.T, configure ...func(*Config)) (*BookHandler, *gin.Engine) {
+	t.Helper()
+	if testClientErr != nil {
+		t.Skipf("no MongoDB available: %v", testClientErr)
+	}
+	cfg := testConfig()
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	name := fmt.Sprintf("library_test_%d_%d", os.Getpid(), testDatabaseID.Add(1))
+	db := testClient.Database(name)
+	t.Cleanup(func() { db.Drop(context.Background()) })
+
+	collection := db.Collection("books")
+	if err := ensureIndexes(testContext(t), collection, cfg); err != nil {
+		t.Fatalf("ensureIndexes: %v", err)
+	}
+	h := NewBookHandler(collection, cfg)
+	return h, newRouter(h, &inFlight{})
+}
+
+// requireReplicaSet skips tests that need transactions or change streams.
+func requireReplicaSet(t *testing.T) {
+	t.Helper()
+	var hello struct {
+		SetName string `bson:"setName"`
+	}
+	err := testClient.Database("admin").RunCommand(testContext(t), bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil || hello.SetName == "" {
+		t.Skip("needs a replica set")
+	}
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// insertBooks stores books directly, bypassing the handlers, and returns
+// their IDs in order.
+func insertBooks(t *testing.T, h *BookHandler, books ...Book) []primitive.ObjectID {
+	t.Helper()
+	ids := make([]primitive.ObjectID, len(books))
+	for i, book := range books {
+		if book.ID.IsZero() {
+			book.ID = primitive.NewObjectID()
+		}
+		if _, err := h.collection.InsertOne(testContext(t), book); err != nil {
+			t.Fatalf("insert %q: %v", book.Title, err)
+		}
+		ids[i] = book.ID
+	}
+	return ids
+}
+
+// findBook reads a book straight from the collection.
+func findBook(t *testing.T, h *BookHandler, id primitive.ObjectID) Book {
+	t.Helper()
+	var book Book
+	if err := h.collection.FindOne(testContext(t), bson.M{"_id": id}).Decode(&book); err != nil {
+		t.Fatalf("find %s: %v", id.Hex(), err)
+	}
+	return book
+}
+
+// serve sends a request through the router. body may be nil, a string or
+// []byte sent as is, or any other value sent as JSON. headers are name,
+// value pairs.
+func serve(router http.Handler, method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = bytes.NewBufferString(b)
+	case []byte:
+		r = bytes.NewReader(b)
+	default:
+		data, _ := json.Marshal(b)
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeBody unmarshals a JSON response, failing the test on bad JSON.
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+}
+
+// expectStatus fails the test unless the response has the given status.
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, code int) {
+	t.Helper()
+	if w.Code != code {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, code, w.Body.String())
+	}
+}
+
+// titles lists the titles of books in order.
+func titles(books []Book) []string {
+	out := make([]string, len(books))
+	for i, book := range books {
+		out[i] = book.Title
+	}
+	return out
+}
+
+// commandLog records the commands a client sends to the server. hold, when
+// set before any request is served, is called as each command starts and
+// delays it until it returns.
+type commandLog struct {
+	hold func(cmd bson.Raw)
+
+	mu       sync.Mutex
+	commands []bson.Raw
+}
+
+func (l *commandLog) started(_ context.Context, e *event.CommandStartedEvent) {
+	if l.hold != nil {
+		l.hold(e.Command)
+	}
+	l.mu.Lock()
+	l.commands = append(l.commands, e.Command)
+	l.mu.Unlock()
+}
+
+// take returns the commands recorded since the last take, keeping only
+// those named, when any are given.
+func (l *commandLog) take(names ...string) []bson.Raw {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var taken []bson.Raw
+	for _, cmd := range l.commands {
+		elems, err := cmd.Elements()
+		if err != nil || len(elems) == 0 {
+			continue
+		}
+		if len(names) == 0 || slices.Contains(names, elems[0].Key()) {
+			taken = append(taken, cmd)
+		}
+	}
+	l.commands = nil
+	return taken
+}
+
+// watchCommands serves h's database through a client of its own whose
+// commands are recorded in the returned log.
+func watchCommands(t *testing.T, h *BookHandler) (*gin.Engine, *commandLog) {
+	t.Helper()
+	log := &commandLog{}
+	client, err := mongo.Connect(testContext(t), options.Client().ApplyURI(testURI).
+		SetMonitor(&event.CommandMonitor{Started: log.started}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	watched := NewBookHandler(client.Database(h.collection.Database().Name()).Collection(h.collection.Name()), h.config)
+	return newRouter(watched, &inFlight{}), log
+}
+
+func TestShutdownCutsOffRequestsPastTheDrainTimeout(t *testing.T) {
+	requests := &inFlight{}
+	router := gin.New()
+	router.Use(requests.middleware())
+	release := make(chan struct{})
+	defer close(release)
+	router.GET("/sleep/:ms", func(c *gin.Context) {
+		ms, _ := time.ParseDuration(c.Param("ms") + "ms")
+		select {
+		case <-time.After(ms):
+		case <-release:
+		}
+		c.String(http.StatusOK, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: router}
+	go srv.Serve(ln)
+
+	type result struct {
+		status int
+		err    error
+	}
+	get := func(ms string) <-chan result {
+		done := make(chan result, 1)
+		go func() {
+			resp, err := http.Get("http://" + ln.Addr().String() + "/sleep/" + ms)
+			if err == nil {
+				_, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+				done <- result{resp.StatusCode, err}
+				return
+			}
+			done <- result{0, err}
+		}()
+		return done
+	}
+	short, long := get("100"), get("5000")
+	for requests.count() < 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	start := time.Now()
+	if cut := shutdown(srv, 500*time.Millisecond, requests); cut != 1 {
+		t.Errorf("shutdown cut off %d request(s), want 1", cut)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %s, want about the drain timeout", elapsed)
+	}
+	if r := <-short; r.err != nil || r.status != http.StatusOK {
+		t.Errorf("short request = %d, %v; want it to complete", r.status, r.err)
+	}
+	if r := <-long; r.err == nil {
+		t.Errorf("long request = %d, want its connection closed", r.status)
+	}
+}
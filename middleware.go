@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inFlight counts the requests currently being served, so shutdown can report
+// how many were cut off.
+type inFlight struct {
+	n atomic.Int64
+}
+
+func (f *inFlight) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f.n.Add(1)
+		defer f.n.Add(-1)
+		c.Next()
+	}
+}
+
+func (f *inFlight) count() int64 {
+	return f.n.Load()
+}
+
+// recovery is gin.Recovery, except that http.ErrAbortHandler is raised again
+// so net/http drops the connection. gin would otherwise end a half-written
+// response normally, and the client could not tell it was cut short.
+func recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, err any) {
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
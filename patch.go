@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// patchableFields are the book fields a PATCH may touch. Each has the same
+// name in JSON and BSON.
+var patchableFields = map[string]bool{
+	"title":        true,
+	"author":       true,
+	"isbn":         true,
+	"price":        true,
+	"publishedAt":  true,
+	"location":     true,
+	"weightGrams":  true,
+	"wordCount":    true,
+	"reorderPoint": true,
+	"tags":         true,
+	"genre":        true,
+	"translations": true,
+	"cost":         true, // admin only
+	"supplier":     true, // admin only
+}
+
+// tagsOp is the operator form of a tags patch.
+type tagsOp struct {
+	Add    []string `json:"$add"`
+	Remove []string `json:"$remove"`
+}
+
+// Partially update a book by ID.
+//
+// Only the fields present in the body change; a field sent as null is removed.
+// "tags" also accepts an operator object instead of a replacement array:
+//
+//	{"tags": {"$add": ["new"]}}    adds tags not already present ($addToSet)
+//	{"tags": {"$remove": ["old"]}} removes every listed tag ($pullAll)
+//
+// One request may use $add or $remove, not both.
+func (h *BookHandler) patchBook(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for field := range body {
+		if !patchableFields[field] || adminOnlyFields[field] && !isAdmin(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("field %q cannot be patched", field)})
+			return
+		}
+	}
+
+	var op *tagsOp
+	if raw, ok := body["tags"]; ok && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		op, err = parseTagsOp(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		delete(body, "tags")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": objID, "deletedAt": notDeleted}
+	// The stored document is decoded twice: merged takes the patch, previous
+	// stays as it was for the webhook event.
+	var current bson.Raw
+	err = h.collection.FindOne(ctx, filter).Decode(&current)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
+		}
+		return
+	}
+	var merged, previous Book
+	if err == nil {
+		err = bson.Unmarshal(current, &merged)
+	}
+	if err == nil {
+		err = bson.Unmarshal(current, &previous)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
+		return
+	}
+
+	// Apply the patch over the stored book so the result can be validated as
+	// a whole. Pointer and map fields are reset first so they are replaced
+	// rather than merged into.
+	for field := range body {
+		switch field {
+		case "location":
+			merged.Location = nil
+		case "translations":
+			merged.Translations = nil
+		}
+	}
+	patch, _ := json.Marshal(body)
+	if err := json.Unmarshal(patch, &merged); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	merged.ISBN = normalizeISBN(merged.ISBN)
+	merged.Author = normalizeAuthor(merged.Author)
+	merged.Genre = normalizeGenre(merged.Genre)
+	if errs := merged.validate(); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": errs})
+		return
+	}
+
+	update, err := patchUpdate(merged, body, op)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
+		return
+	}
+	if len(update) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	var book Book
+	err = h.writeUnderAuthorCap(ctx, previous.Author, merged.Author, func(ctx context.Context) error {
+		return h.collection.FindOneAndUpdate(
+			ctx,
+			filter,
+			update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&book)
+	})
+	if h.authorCapReply(c, err) || h.conflictReply(c, err) {
+		return
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Book not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating book"})
+		return
+	}
+
+	h.recordPriceChange(ctx, book.ID, previous.Price, book.Price)
+	h.bumpGeneration(ctx)
+	h.notifyChange(eventBookUpdated, book.ID, &previous, &book, h.now().UTC())
+
+	if etag, err := bookETag(book); err == nil {
+		c.Header("ETag", etag)
+	}
+
+	h.scopeBook(c, &book)
+	c.JSON(http.StatusOK, book)
+}
+
+func parseTagsOp(raw json.RawMessage) (*tagsOp, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var op tagsOp
+	if err := dec.Decode(&op); err != nil {
+		return nil, fmt.Errorf("tags: only $add and $remove are supported: %v", err)
+	}
+	if (len(op.Add) == 0) == (len(op.Remove) == 0) {
+		return nil, fmt.Errorf("tags: give exactly one of $add or $remove")
+	}
+	for _, tag := range append(op.Add, op.Remove...) {
+		if strings.TrimSpace(tag) == "" {
+			return nil, fmt.Errorf("tags: tags must not be blank")
+		}
+	}
+	return &op, nil
+}
+
+// patchUpdate turns the fields present in body into $set/$unset using their
+// values in merged, plus the tags operator if one was given.
+func patchUpdate(merged Book, body map[string]json.RawMessage, op *tagsOp) (bson.M, error) {
+	data, err := bson.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var stored bson.M
+	if err := bson.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	for field := range body {
+		if value, ok := stored[field]; ok {
+			set[field] = value
+		} else {
+			unset[field] = ""
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if op != nil && len(op.Add) > 0 {
+		update["$addToSet"] = bson.M{"tags": bson.M{"$each": op.Add}}
+	}
+	if op != nil && len(op.Remove) > 0 {
+		update["$pullAll"] = bson.M{"tags": op.Remove}
+	}
+	return update, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPatchTagOperatorsKeepOtherTags(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99, Tags: []string{"classic", "scifi"}})
+	path := "/books/" + ids[0].Hex()
+
+	for _, tt := range []struct {
+		body string
+		want []string
+	}{
+		{`{"tags": {"$add": ["desert", "scifi"]}}`, []string{"classic", "scifi", "desert"}},
+		{`{"tags": {"$remove": ["classic", "missing"]}}`, []string{"scifi", "desert"}},
+		// Other fields in the same request are still set.
+		{`{"price": 12, "tags": {"$add": ["epic"]}}`, []string{"scifi", "desert", "epic"}},
+	} {
+		w := serve(router, http.MethodPatch, path, tt.body)
+		expectStatus(t, w, http.StatusOK)
+		var book Book
+		decodeBody(t, w, &book)
+		if !reflect.DeepEqual(book.Tags, tt.want) {
+			t.Errorf("%s: response tags = %v, want %v", tt.body, book.Tags, tt.want)
+		}
+		if got := findBook(t, h, ids[0]).Tags; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: stored tags = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+	if book := findBook(t, h, ids[0]); book.Price != 12 || book.Title != "Dune" {
+		t.Fatalf("book = %+v, want only price changed", book)
+	}
+
+	for _, body := range []string{
+		`{"tags": {"$add": ["a"], "$remove": ["b"]}}`,
+		`{"tags": {"$replace": ["a"]}}`,
+	} {
+		w := serve(router, http.MethodPatch, path, body)
+		expectStatus(t, w, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	ginrender "github.com/gin-gonic/gin/render"
+)
+
+// renderFormats are the response types offered to content negotiation, JSON
+// first so that a missing or wildcard Accept keeps getting JSON.
+var renderFormats = []string{"application/json", "application/msgpack", "application/x-msgpack"}
+
+// renderBook writes a single book as seen by this request.
+func (h *BookHandler) renderBook(c *gin.Context, code int, book Book) {
+	h.presentBook(c, &book)
+	render(c, code, book)
+}
+
+// renderBooks writes a list of books as seen by this request.
+func (h *BookHandler) renderBooks(c *gin.Context, code int, books []Book) {
+	h.presentBooks(c, books)
+	render(c, code, books)
+}
+
+// render writes a read response as MessagePack when the client asks for it
+// and JSON otherwise. The msgpack codec falls back to the json struct tags.
+func render(c *gin.Context, code int, obj interface{}) {
+	c.Header("Vary", "Accept, Accept-Language")
+	if format := c.NegotiateFormat(renderFormats...); format != "" && format != "application/json" {
+		// The msgpack codec ignores Book.MarshalJSON, so a version-limited
+		// response goes through JSON first to drop the hidden fields.
+		if c.GetString(apiVersionKey) == "1" {
+			if data, err := json.Marshal(obj); err == nil {
+				var projected interface{}
+				if json.Unmarshal(data, &projected) == nil {
+					obj = projected
+				}
+			}
+		}
+		c.Render(code, ginrender.MsgPack{Data: obj})
+		return
+	}
+	c.JSON(code, obj)
+}
+
+func (h *BookHandler) presentBooks(c *gin.Context, books []Book) {
+	for i := range books {
+		h.presentBook(c, &books[i])
+	}
+}
+
+// presentBook adapts a stored book to the request before it is written out:
+// admin-only fields are redacted, fields newer than the request's API version
+// are hidden, computed fields are filled in and the title is swapped for the best
+// Accept-Language translation.
+func (h *BookHandler) presentBook(c *gin.Context, book *Book) {
+	h.scopeBook(c, book)
+	book.ReadingMinutes = readingMinutes(book.WordCount, h.config.WordsPerMinute)
+
+	if len(book.Translations) == 0 {
+		return
+	}
+	for _, locale := range acceptedLocales(c.GetHeader("Accept-Language")) {
+		if title, ok := lookupTranslation(book.Translations, locale); ok {
+			book.Title = title
+			return
+		}
+	}
+}
+
+// scopeBook limits a book to what the caller may see: admin-only fields are
+// cleared and fields newer than its API version are hidden.
+func (h *BookHandler) scopeBook(c *gin.Context, book *Book) {
+	redactBook(c, book)
+	book.visible = h.versionFields(c)
+}
+
+// readingMinutes rounds up, so any text at all takes at least a minute.
+func readingMinutes(words, wordsPerMinute int) int {
+	if words <= 0 {
+		return 0
+	}
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// lookupTranslation tries the exact locale, then its base language, so
+// "fr-CA" falls back to a "fr" title.
+func lookupTranslation(translations map[string]string, locale string) (string, bool) {
+	if title, ok := translations[locale]; ok {
+		return title, true
+	}
+	if base, _, found := strings.Cut(locale, "-"); found {
+		title, ok := translations[base]
+		return title, ok
+	}
+	return "", false
+}
+
+// acceptedLocales parses an Accept-Language header into lower-cased locales,
+// most preferred first. Wildcards and q=0 entries are dropped.
+func acceptedLocales(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var entries []weighted
+	for _, part := range strings.Split(header, ",") {
+		locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		locale = strings.ToLower(strings.TrimSpace(locale))
+		if locale == "" || locale == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			entries = append(entries, weighted{locale: locale, q: q})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].q > entries[j].q })
+
+	locales := make([]string, len(entries))
+	for i, e := range entries {
+		locales[i] = e.locale
+	}
+	return locales
+}
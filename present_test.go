@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+func TestTitleFollowsAcceptLanguage(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{
+		Title:        "The Little Prince",
+		Author:       "Antoine de Saint-Exupéry",
+		Translations: map[string]string{"fr": "Le Petit Prince", "de": "Der kleine Prinz"},
+	})
+	path := "/books/" + ids[0].Hex()
+
+	for _, tt := range []struct {
+		acceptLanguage string
+		want           string
+	}{
+		// fr-CA has no entry of its own, so its base language is used.
+		{"fr-CA, de;q=0.8", "Le Petit Prince"},
+		{"es;q=0.9, de;q=0.5", "Der kleine Prinz"},
+		{"es, it", "The Little Prince"},
+		{"", "The Little Prince"},
+	} {
+		w := serve(router, http.MethodGet, path, nil, "Accept-Language", tt.acceptLanguage)
+		expectStatus(t, w, http.StatusOK)
+		var book Book
+		decodeBody(t, w, &book)
+		if book.Title != tt.want {
+			t.Errorf("Accept-Language %q: title = %q, want %q", tt.acceptLanguage, book.Title, tt.want)
+		}
+	}
+}
+
+func TestReadingMinutesAndShortReads(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.WordsPerMinute = 200 })
+	insertBooks(t, h,
+		Book{Title: "Essay", Author: "A", WordCount: 1000},
+		Book{Title: "Novella", Author: "B", WordCount: 1001},
+		Book{Title: "Novel", Author: "C", WordCount: 60000},
+		Book{Title: "Uncounted", Author: "D"},
+	)
+
+	w := serve(router, http.MethodGet, "/books?sort=title", nil)
+	expectStatus(t, w, http.StatusOK)
+	var books []Book
+	decodeBody(t, w, &books)
+	minutes := map[string]int{}
+	for _, book := range books {
+		minutes[book.Title] = book.ReadingMinutes
+	}
+	// Partial minutes round up; a book without a word count has no estimate.
+	want := map[string]int{"Essay": 5, "Novella": 6, "Novel": 300, "Uncounted": 0}
+	if !reflect.DeepEqual(minutes, want) {
+		t.Fatalf("readingMinutes = %v, want %v", minutes, want)
+	}
+
+	w = serve(router, http.MethodGet, "/books?sort=title&max_reading_minutes=6", nil)
+	expectStatus(t, w, http.StatusOK)
+	books = nil
+	decodeBody(t, w, &books)
+	if got, want := titles(books), []string{"Essay", "Novella"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("max_reading_minutes=6 = %v, want %v", got, want)
+	}
+}
+
+func TestMsgpackDecodesBackIntoBook(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99, Tags: []string{"scifi"}})
+
+	w := serve(router, http.MethodGet, "/books/"+ids[0].Hex(), nil, "Accept", "application/msgpack")
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/msgpack") {
+		t.Fatalf("Content-Type = %q, want msgpack", ct)
+	}
+	var mh codec.MsgpackHandle
+	var book Book
+	if err := codec.NewDecoderBytes(w.Body.Bytes(), &mh).Decode(&book); err != nil {
+		t.Fatalf("decode msgpack: %v", err)
+	}
+	if book.ID != ids[0] || book.Title != "Dune" || book.Price != 9.99 || !reflect.DeepEqual(book.Tags, []string{"scifi"}) {
+		t.Fatalf("decoded %+v, want the stored Dune", book)
+	}
+
+	// Without the Accept header the same endpoint still answers JSON.
+	w = serve(router, http.MethodGet, "/books/"+ids[0].Hex(), nil)
+	expectStatus(t, w, http.StatusOK)
+	book = Book{}
+	decodeBody(t, w, &book)
+	if book.Title != "Dune" {
+		t.Fatalf("JSON fallback = %+v", book)
+	}
+}
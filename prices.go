@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PriceChange is one entry in the price_history collection.
+type PriceChange struct {
+	BookID    primitive.ObjectID `json:"-" bson:"bookId"`
+	Price     float64            `json:"price" bson:"price"`
+	ChangedAt time.Time          `json:"changedAt" bson:"changedAt"`
+}
+
+// recordPriceChange logs a new price for the book when it differs from the
+// old one. A failure is logged rather than failing the write that caused it.
+func (h *BookHandler) recordPriceChange(ctx context.Context, bookID primitive.ObjectID, oldPrice, newPrice float64) {
+	if samePrice(oldPrice, newPrice) {
+		return
+	}
+	change := PriceChange{BookID: bookID, Price: newPrice, ChangedAt: h.now().UTC()}
+	if _, err := h.prices.InsertOne(ctx, change); err != nil {
+		log.Printf("price history for %s: %v", bookID.Hex(), err)
+	}
+}
+
+// Get a book's price changes, oldest first, optionally within ?from= and ?to=
+func (h *BookHandler) getPriceHistory(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	changedAt, err := dateRangeFilter(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	err = h.reader(c).FindOne(
+		ctx,
+		bson.M{"_id": objID, "deletedAt": notDeleted},
+		options.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		}
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving book"})
+		return
+	}
+
+	filter := bson.M{"bookId": objID}
+	if changedAt != nil {
+		filter["changedAt"] = changedAt
+	}
+	cursor, err := h.readerOf(c, h.prices).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "changedAt", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving price history"})
+		return
+	}
+	history := make([]PriceChange, 0)
+	if err := cursor.All(ctx, &history); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving price history"})
+		return
+	}
+
+	render(c, http.StatusOK, history)
+}
+
+// adjustBatchSize bounds how many books adjustPrices reprices per update.
+const adjustBatchSize = 500
+
+// adjustedPrice is the aggregation expression for price × factor rounded to
+// cents.
+func adjustedPrice(factor float64) bson.M {
+	return bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{"$price", factor}}, 2}}
+}
+
+// Multiply the price of every book matching the listing filters by
+// {"factor": f}, rounding to cents. Unlike the listing, out-of-print books are
+// included unless ?include_out_of_print=false
+func (h *BookHandler) adjustPrices(c *gin.Context) {
+	var req struct {
+		Factor float64 `json:"factor"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Factor <= 0 || math.IsInf(req.Factor, 0) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Validation failed",
+			"fields": []FieldError{{Field: "factor", Message: "must be a positive number"}},
+		})
+		return
+	}
+	query, err := h.listValues(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !query.Has("include_out_of_print") {
+		query.Set("include_out_of_print", "true")
+	}
+	filter, _, err := h.listFilter(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter["price"] = bson.M{"$type": "number"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// Walk the matches in _id order, a batch at a time, so neither the IDs nor
+	// the books are ever all in memory. Each batch is updated first and only
+	// then compared with the prices read before it, so history and webhooks
+	// cover exactly the changes that were written.
+	cursor, err := h.collection.Find(ctx, filter, options.Find().
+		SetProjection(bson.M{"price": 1}).
+		SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adjusting prices"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var (
+		matched, modified int64
+		oldPrices         = make(map[primitive.ObjectID]float64, adjustBatchSize)
+	)
+	flush := func() error {
+		if len(oldPrices) == 0 {
+			return nil
+		}
+		ids := make([]primitive.ObjectID, 0, len(oldPrices))
+		for id := range oldPrices {
+			ids = append(ids, id)
+		}
+		result, err := h.collection.UpdateMany(
+			ctx,
+			bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$in": ids}}}},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{"price": adjustedPrice(req.Factor)}}}},
+		)
+		if err != nil {
+			return err
+		}
+		matched += result.MatchedCount
+		modified += result.ModifiedCount
+		if result.ModifiedCount > 0 {
+			h.bumpGeneration(ctx)
+			h.recordAdjustedPrices(ctx, ids, oldPrices)
+		}
+		clear(oldPrices)
+		return nil
+	}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Price float64            `bson:"price"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adjusting prices", "modified": modified})
+			return
+		}
+		oldPrices[doc.ID] = doc.Price
+		if len(oldPrices) == adjustBatchSize {
+			if err := flush(); err != nil {
+				log.Printf("adjust prices: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adjusting prices", "modified": modified})
+				return
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adjusting prices", "modified": modified})
+		return
+	}
+	if err := flush(); err != nil {
+		log.Printf("adjust prices: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adjusting prices", "modified": modified})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"factor": req.Factor, "matched": matched, "modified": modified})
+}
+
+// recordAdjustedPrices reads back books just repriced by adjustPrices and, for
+// each whose price moved, records the change and sends a book.updated. Like
+// recordPriceChange, failures are logged rather than failing the adjustment.
+func (h *BookHandler) recordAdjustedPrices(ctx context.Context, ids []primitive.ObjectID, oldPrices map[primitive.ObjectID]float64) {
+	books, err := h.findBooks(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		log.Printf("adjust prices: reading changed books: %v", err)
+		return
+	}
+	changedAt := h.now().UTC()
+	history := make([]interface{}, 0, len(books))
+	for i := range books {
+		if samePrice(oldPrices[books[i].ID], books[i].Price) {
+			continue
+		}
+		history = append(history, PriceChange{BookID: books[i].ID, Price: books[i].Price, ChangedAt: changedAt})
+		previous := books[i]
+		previous.Price = oldPrices[books[i].ID]
+		h.webhooks.notify(newWebhookEvent(eventBookUpdated, books[i].ID, &previous, &books[i], changedAt))
+	}
+	if len(history) == 0 {
+		return
+	}
+	if _, err := h.prices.InsertMany(ctx, history); err != nil {
+		log.Printf("adjust prices: price history: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdjustPricesRoundsToCents(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.WebhookURLs = []string{server.URL} })
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99},
+		Book{Title: "Emma", Author: "Jane Austen", Price: 4.5, OutOfPrint: true},
+		Book{Title: "Free", Author: "Anon", Price: 0},
+	)
+	body := map[string]float64{"factor": 1.1}
+
+	expectStatus(t, serve(router, http.MethodPost, "/books/adjust-prices", body), http.StatusUnauthorized)
+
+	w := serve(router, http.MethodPost, "/books/adjust-prices", body, asAdmin...)
+	expectStatus(t, w, http.StatusOK)
+	var result struct {
+		Matched  int64 `json:"matched"`
+		Modified int64 `json:"modified"`
+	}
+	decodeBody(t, w, &result)
+	if result.Matched != 3 || result.Modified != 2 {
+		t.Fatalf("result = %+v, want 3 matched and 2 modified", result)
+	}
+
+	// 10.989 and 4.95000000000001 round to cents; the out-of-print book is
+	// adjusted too.
+	for i, want := range []float64{10.99, 4.95, 0} {
+		if got := findBook(t, h, ids[i]).Price; got != want {
+			t.Errorf("price %d = %v, want %v", i, got, want)
+		}
+	}
+
+	// One book.updated and one history entry per changed book.
+	receiver.waitFor(t, 2)
+	for i, want := range []int{1, 1, 0} {
+		w := serve(router, http.MethodGet, "/books/"+ids[i].Hex()+"/price-history", nil)
+		expectStatus(t, w, http.StatusOK)
+		var history []PriceChange
+		decodeBody(t, w, &history)
+		if len(history) != want {
+			t.Errorf("price-history %d has %d entries, want %d", i, len(history), want)
+		}
+	}
+}
+
+func TestPriceHistoryRecordsEachChange(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99})
+	path := "/books/" + ids[0].Hex()
+
+	for _, step := range []struct {
+		day  int
+		body string
+	}{
+		{1, `{"price": 12.5}`},
+		// Not a price change, so not a point in the history.
+		{3, `{"title": "Dune (Deluxe)"}`},
+		{5, `{"price": 11}`},
+	} {
+		at := time.Date(2024, time.March, step.day, 12, 0, 0, 0, time.UTC)
+		h.now = func() time.Time { return at }
+		expectStatus(t, serve(router, http.MethodPatch, path, step.body), http.StatusOK)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"12.5@2024-03-01", "11@2024-03-05"}},
+		{"?from=2024-03-02", []string{"11@2024-03-05"}},
+		{"?to=2024-03-01", []string{"12.5@2024-03-01"}},
+	} {
+		w := serve(router, http.MethodGet, path+"/price-history"+tt.query, nil)
+		expectStatus(t, w, http.StatusOK)
+		var history []PriceChange
+		decodeBody(t, w, &history)
+		got := []string{}
+		for _, p := range history {
+			got = append(got, fmt.Sprintf("%v@%s", p.Price, p.ChangedAt.Format(time.DateOnly)))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("price-history%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
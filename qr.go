@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+)
+
+// Get a PNG QR code linking to the book, ?size= pixels square
+func (h *BookHandler) getBookQR(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	size := defaultQRSize
+	if raw := c.Query("size"); raw != "" {
+		size, err = strconv.Atoi(raw)
+		if err != nil || size < minQRSize || size > maxQRSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 64 and 1024"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	err = h.collection.FindOne(
+		ctx,
+		bson.M{"_id": objID, "deletedAt": notDeleted},
+		options.FindOne().SetProjection(bson.M{"_id": 1}),
+	).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving book"})
+		return
+	}
+
+	png, err := qrcode.Encode(h.config.PublicURL+"/books/"+objID.Hex(), qrcode.Medium, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating QR code"})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBookQRIsAPNGOfTheRequestedSize(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert"})
+
+	w := serve(router, http.MethodGet, "/books/"+ids[0].Hex()+"/qr?size=128", nil)
+	expectStatus(t, w, http.StatusOK)
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("Content-Type = %q, want image/png", ct)
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("decode PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 128 {
+		t.Fatalf("image is %dx%d, want 128x128", b.Dx(), b.Dy())
+	}
+
+	w = serve(router, http.MethodGet, "/books/"+ids[0].Hex()+"/qr?size=10", nil)
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = serve(router, http.MethodGet, "/books/"+primitive.NewObjectID().Hex()+"/qr", nil)
+	expectStatus(t, w, http.StatusNotFound)
+}
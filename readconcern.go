@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+)
+
+// readConcernKey is the gin context key holding the requested read concern.
+const readConcernKey = "readConcern"
+
+// readConcerns are the levels accepted by ?read_concern= and READ_CONCERN.
+var readConcerns = map[string]*readconcern.ReadConcern{
+	"local":        readconcern.Local(),
+	"majority":     readconcern.Majority(),
+	"linearizable": readconcern.Linearizable(),
+}
+
+// newReaders clones each collection once per read concern level, so a
+// request only has to pick one. The result is keyed by collection name, then
+// level.
+func newReaders(collections ...*mongo.Collection) map[string]map[string]*mongo.Collection {
+	readers := make(map[string]map[string]*mongo.Collection, len(collections))
+	for _, collection := range collections {
+		byLevel := make(map[string]*mongo.Collection, len(readConcerns))
+		for level, rc := range readConcerns {
+			clone, err := collection.Clone(options.Collection().SetReadConcern(rc))
+			if err != nil {
+				clone = collection
+			}
+			byLevel[level] = clone
+		}
+		readers[collection.Name()] = byLevel
+	}
+	return readers
+}
+
+// readConcern validates ?read_concern=, rejecting unknown levels with 400.
+func (h *BookHandler) readConcern() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if level := c.Query("read_concern"); level != "" {
+			if _, ok := readConcerns[level]; !ok {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read_concern must be local, majority or linearizable"})
+				return
+			}
+			c.Set(readConcernKey, level)
+		}
+		c.Next()
+	}
+}
+
+// readLevel is the read concern for this request: ?read_concern= if given,
+// else READ_CONCERN.
+func (h *BookHandler) readLevel(c *gin.Context) string {
+	if level := c.GetString(readConcernKey); level != "" {
+		return level
+	}
+	return h.config.ReadConcern
+}
+
+// reader is the books collection to read from for this request.
+func (h *BookHandler) reader(c *gin.Context) *mongo.Collection {
+	return h.readerOf(c, h.collection)
+}
+
+// readerOf is collection as read with this request's read concern. Only
+// collections given to newReaders have one; others are returned unchanged.
+func (h *BookHandler) readerOf(c *gin.Context, collection *mongo.Collection) *mongo.Collection {
+	if coll, ok := h.readers[collection.Name()][h.readLevel(c)]; ok {
+		return coll
+	}
+	return collection
+}
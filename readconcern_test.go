@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadEndpointsApplyTheRequestedReadConcern(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99, Stock: 1, ReorderPoint: 2})
+	if _, err := h.sales.InsertOne(testContext(t), Sale{BookID: ids[0], Quantity: 1, SoldAt: time.Now(), OrderID: "o1"}); err != nil {
+		t.Fatal(err)
+	}
+	router, commands := watchCommands(t, h)
+
+	book := "/books/" + ids[0].Hex()
+	for _, path := range []string{
+		"/books/stats",
+		"/books/by-decade",
+		"/books/compare-authors?a=Frank+Herbert&b=Jane+Austen",
+		"/books/coverage?target=2",
+		"/books/publication-span",
+		"/books/below-reorder",
+		"/books/restock-suggestions",
+		book + "?expand=similar",
+		book + "/availability",
+		book + "/price-history",
+		book + "/frequently-bought-with",
+	} {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		commands.take()
+		w := serve(router, http.MethodGet, path+sep+"read_concern=majority", nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d: %s", path, w.Code, w.Body)
+			continue
+		}
+		reads := commands.take("find", "aggregate", "distinct", "count")
+		if len(reads) == 0 {
+			t.Errorf("%s: no read commands seen", path)
+		}
+		for _, read := range reads {
+			if level, _ := read.Lookup("readConcern", "level").StringValueOK(); level != "majority" {
+				t.Errorf("%s: read concern %q on %s, want majority", path, level, read)
+			}
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type decadeCount struct {
+	Decade string `json:"decade"`
+	Count  int    `json:"count"`
+}
+
+type datedTitle struct {
+	PublishedAt time.Time `json:"publishedAt" bson:"publishedAt"`
+	Title       string    `json:"title" bson:"title"`
+}
+
+// Get the earliest and latest publication dates with their titles; both are
+// null when no book has a date
+func (h *BookHandler) getPublicationSpan(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// Documents compare field by field, so with publishedAt first $min and
+	// $max pick the extreme dates and carry the title along.
+	dated := bson.D{{Key: "publishedAt", Value: "$publishedAt"}, {Key: "title", Value: "$title"}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deletedAt": notDeleted, "publishedAt": bson.M{"$type": "date"}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "earliest", Value: bson.D{{Key: "$min", Value: dated}}},
+			{Key: "latest", Value: bson.D{{Key: "$max", Value: dated}}},
+		}}},
+	}
+	cursor, err := h.reader(c).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+	var span []struct {
+		Earliest *datedTitle `json:"earliest" bson:"earliest"`
+		Latest   *datedTitle `json:"latest" bson:"latest"`
+	}
+	if err := cursor.All(ctx, &span); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+
+	if len(span) == 0 {
+		c.JSON(http.StatusOK, gin.H{"earliest": nil, "latest": nil})
+		return
+	}
+	c.JSON(http.StatusOK, span[0])
+}
+
+type genreGap struct {
+	Genre  string `json:"genre"`
+	Count  int    `json:"count"`
+	Needed int    `json:"needed"`
+}
+
+// Report how many more books each known genre needs to reach ?target=,
+// largest gap first
+func (h *BookHandler) getCoverage(c *gin.Context) {
+	target, err := strconv.Atoi(c.Query("target"))
+	if err != nil || target < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target must be a positive integer"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deletedAt": notDeleted, "genre": bson.M{"$in": h.config.KnownGenres}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$genre"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	}
+	cursor, err := h.reader(c).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+	var rows []struct {
+		Genre string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Genre] = row.Count
+	}
+
+	// Every known genre is reported, including those with no books yet.
+	gaps := make([]genreGap, 0, len(h.config.KnownGenres))
+	for _, genre := range h.config.KnownGenres {
+		gap := genreGap{Genre: genre, Count: counts[genre]}
+		if gap.Count < target {
+			gap.Needed = target - gap.Count
+		}
+		gaps = append(gaps, gap)
+	}
+	sort.SliceStable(gaps, func(i, j int) bool { return gaps[i].Needed > gaps[j].Needed })
+
+	c.JSON(http.StatusOK, gin.H{"target": target, "genres": gaps})
+}
+
+// Count books per publication decade, with undated books under "unknown"
+func (h *BookHandler) getBooksByDecade(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// Dated books group on floor(year/10)*10; anything else groups on null.
+	decade := bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$publishedAt"}}, "date"}}},
+		bson.D{{Key: "$multiply", Value: bson.A{
+			bson.D{{Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{
+				bson.D{{Key: "$year", Value: "$publishedAt"}}, 10,
+			}}}}},
+			10,
+		}}},
+		nil,
+	}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deletedAt": notDeleted}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: decade},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+
+	cursor, err := h.reader(c).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+	var rows []struct {
+		Decade *int `bson:"_id"`
+		Count  int  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+
+	decades := make([]decadeCount, 0, len(rows))
+	unknown := 0
+	for _, row := range rows {
+		if row.Decade == nil {
+			unknown = row.Count
+			continue
+		}
+		decades = append(decades, decadeCount{Decade: fmt.Sprintf("%ds", *row.Decade), Count: row.Count})
+	}
+	if unknown > 0 {
+		decades = append(decades, decadeCount{Decade: "unknown", Count: unknown})
+	}
+
+	c.JSON(http.StatusOK, decades)
+}
+
+type authorStats struct {
+	Author       string   `json:"author" bson:"-"`
+	Count        int      `json:"count" bson:"count"`
+	AveragePrice *float64 `json:"averagePrice" bson:"averagePrice"`
+	MinPrice     *float64 `json:"minPrice" bson:"minPrice"`
+	MaxPrice     *float64 `json:"maxPrice" bson:"maxPrice"`
+}
+
+// Compare two authors' book counts and prices side by side
+func (h *BookHandler) compareAuthors(c *gin.Context) {
+	// Stored authors are normalized, so the names asked for must be too.
+	a, b := normalizeAuthor(c.Query("a")), normalizeAuthor(c.Query("b"))
+	if a == "" || b == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Both a and b authors are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	facet := func(author string) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"author": author}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: nil},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "averagePrice", Value: bson.D{{Key: "$avg", Value: "$price"}}},
+				{Key: "minPrice", Value: bson.D{{Key: "$min", Value: "$price"}}},
+				{Key: "maxPrice", Value: bson.D{{Key: "$max", Value: "$price"}}},
+			}}},
+		}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"author": bson.M{"$in": bson.A{a, b}}, "deletedAt": notDeleted}}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "a", Value: facet(a)},
+			{Key: "b", Value: facet(b)},
+		}}},
+	}
+
+	cursor, err := h.reader(c).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error comparing authors"})
+		return
+	}
+	var rows []struct {
+		A []authorStats `bson:"a"`
+		B []authorStats `bson:"b"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil || len(rows) != 1 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error comparing authors"})
+		return
+	}
+
+	// An author without books gets an empty facet; report zero rather than omit.
+	pick := func(author string, stats []authorStats) authorStats {
+		result := authorStats{}
+		if len(stats) > 0 {
+			result = stats[0]
+		}
+		result.Author = author
+		return result
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"a": pick(a, rows[0].A),
+		"b": pick(b, rows[0].B),
+	})
+}
+
+type catalogStats struct {
+	Count        int      `json:"count" bson:"count"`
+	AveragePrice *float64 `json:"averagePrice" bson:"averagePrice"`
+	MinPrice     *float64 `json:"minPrice" bson:"minPrice"`
+	MaxPrice     *float64 `json:"maxPrice" bson:"maxPrice"`
+	TotalStock   int      `json:"totalStock" bson:"totalStock"`
+}
+
+// Get catalog stats, limited to books created within ?from= and ?to=
+func (h *BookHandler) getStats(c *gin.Context) {
+	match := bson.M{"deletedAt": notDeleted}
+	created, err := dateRangeFilter(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if created != nil {
+		match["createdAt"] = created
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "averagePrice", Value: bson.D{{Key: "$avg", Value: "$price"}}},
+			{Key: "minPrice", Value: bson.D{{Key: "$min", Value: "$price"}}},
+			{Key: "maxPrice", Value: bson.D{{Key: "$max", Value: "$price"}}},
+			{Key: "totalStock", Value: bson.D{{Key: "$sum", Value: "$stock"}}},
+		}}},
+	}
+
+	cursor, err := h.reader(c).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+	var rows []catalogStats
+	if err := cursor.All(ctx, &rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating books"})
+		return
+	}
+
+	stats := catalogStats{}
+	if len(rows) > 0 {
+		stats = rows[0]
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// dateRangeFilter builds an inclusive range from optional from/to values, each
+// either RFC 3339 or a plain date; a plain "to" date covers that whole day.
+// It returns nil when neither bound is given.
+func dateRangeFilter(fromRaw, toRaw string) (bson.M, error) {
+	if fromRaw == "" && toRaw == "" {
+		return nil, nil
+	}
+	rng := bson.M{}
+	var from, to time.Time
+	toExclusive := false
+	if fromRaw != "" {
+		t, _, err := parseDateParam(fromRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %v", err)
+		}
+		from = t
+		rng["$gte"] = from
+	}
+	if toRaw != "" {
+		t, dateOnly, err := parseDateParam(toRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %v", err)
+		}
+		to = t
+		if dateOnly {
+			// The range runs up to, not including, midnight after that day.
+			to, toExclusive = t.AddDate(0, 0, 1), true
+			rng["$lt"] = to
+		} else {
+			rng["$lte"] = to
+		}
+	}
+	if fromRaw != "" && toRaw != "" && (from.After(to) || toExclusive && !from.Before(to)) {
+		return nil, fmt.Errorf("from must not be after to")
+	}
+	return rng, nil
+}
+
+// parseDateParam accepts RFC 3339 timestamps or YYYY-MM-DD dates (UTC).
+func parseDateParam(raw string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+	if t, err = time.Parse("2006-01-02", raw); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%q is not a date", raw)
+}
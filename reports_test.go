@@ -0,0 +1,181 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDateRangeFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		from, to string
+		want     bson.M
+		wantErr  bool
+	}{
+		{from: "", to: "", want: nil},
+		{from: "2024-03-01", to: "2024-03-05", want: bson.M{"$gte": day(1), "$lt": day(6)}},
+		{from: "2024-03-05", to: "2024-03-05", want: bson.M{"$gte": day(5), "$lt": day(6)}},
+		// A time later on the "to" day is still inside that day.
+		{from: "2024-03-05T18:30:00Z", to: "2024-03-05", want: bson.M{"$gte": day(5).Add(18*time.Hour + 30*time.Minute), "$lt": day(6)}},
+		{from: "2024-03-01T00:00:00Z", to: "2024-03-05T12:00:00Z", want: bson.M{"$gte": day(1), "$lte": day(5).Add(12 * time.Hour)}},
+		{from: "2024-03-06T00:00:00Z", to: "2024-03-05", wantErr: true},
+		{from: "2024-03-06", to: "2024-03-05", wantErr: true},
+		{from: "2024-03-05T12:00:01Z", to: "2024-03-05T12:00:00Z", wantErr: true},
+		{from: "yesterday", to: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := dateRangeFilter(tt.from, tt.to)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("dateRangeFilter(%q, %q) = %v, want an error", tt.from, tt.to, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("dateRangeFilter(%q, %q): %v", tt.from, tt.to, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("dateRangeFilter(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			continue
+		}
+		for op, bound := range tt.want {
+			if g, ok := got[op].(time.Time); !ok || !g.Equal(bound.(time.Time)) {
+				t.Errorf("dateRangeFilter(%q, %q)[%s] = %v, want %v", tt.from, tt.to, op, got[op], bound)
+			}
+		}
+	}
+}
+
+func TestBooksByDecadeBucketsPublicationDates(t *testing.T) {
+	h, router := newTestHandler(t)
+	year := func(y int) *time.Time {
+		d := time.Date(y, 6, 1, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", PublishedAt: year(1965)},
+		Book{Title: "Children of Dune", Author: "Frank Herbert", PublishedAt: year(1969)},
+		Book{Title: "Neuromancer", Author: "William Gibson", PublishedAt: year(1984)},
+		Book{Title: "Anathem", Author: "Neal Stephenson", PublishedAt: year(2008)},
+		Book{Title: "Beowulf", Author: "Anonymous"},
+	)
+
+	w := serve(router, http.MethodGet, "/books/by-decade", nil)
+	expectStatus(t, w, http.StatusOK)
+	var decades []decadeCount
+	decodeBody(t, w, &decades)
+	want := []decadeCount{
+		{Decade: "1960s", Count: 2},
+		{Decade: "1980s", Count: 1},
+		{Decade: "2000s", Count: 1},
+		{Decade: "unknown", Count: 1},
+	}
+	if !reflect.DeepEqual(decades, want) {
+		t.Fatalf("decades = %+v, want %+v", decades, want)
+	}
+}
+
+func TestCompareAuthorsIncludingOneWithoutBooks(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Price: 10},
+		Book{Title: "Children of Dune", Author: "Frank Herbert", Price: 6},
+		Book{Title: "Emma", Author: "Jane Austen", Price: 4.5},
+	)
+
+	// The names are matched as stored, whatever their case and spacing.
+	w := serve(router, http.MethodGet, "/books/compare-authors?a=frank++HERBERT&b=nobody+atall", nil)
+	expectStatus(t, w, http.StatusOK)
+	var got struct {
+		A, B authorStats
+	}
+	decodeBody(t, w, &got)
+	price := func(p float64) *float64 { return &p }
+	wantA := authorStats{Author: "Frank Herbert", Count: 2, AveragePrice: price(8), MinPrice: price(6), MaxPrice: price(10)}
+	wantB := authorStats{Author: "Nobody Atall"}
+	if !reflect.DeepEqual(got.A, wantA) || !reflect.DeepEqual(got.B, wantB) {
+		t.Fatalf("comparison = %+v / %+v, want %+v / %+v", got.A, got.B, wantA, wantB)
+	}
+}
+
+func TestCoverageGapsPerKnownGenre(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) {
+		cfg.KnownGenres = []string{"fantasy", "history", "poetry", "scifi"}
+	})
+	deleted := time.Now()
+	var books []Book
+	for genre, n := range map[string]int{"scifi": 3, "history": 1, "fantasy": 5, "romance": 2} {
+		for range n {
+			books = append(books, Book{Title: genre, Author: "A", Genre: genre})
+		}
+	}
+	// Deleted books do not count toward coverage.
+	books = append(books, Book{Title: "Gone", Author: "A", Genre: "history", DeletedAt: &deleted})
+	insertBooks(t, h, books...)
+
+	w := serve(router, http.MethodGet, "/books/coverage?target=3", nil)
+	expectStatus(t, w, http.StatusOK)
+	var body struct {
+		Target int        `json:"target"`
+		Genres []genreGap `json:"genres"`
+	}
+	decodeBody(t, w, &body)
+	// Largest gaps first; poetry has no books but is still listed, and
+	// romance is not a known genre.
+	want := []genreGap{
+		{Genre: "poetry", Count: 0, Needed: 3},
+		{Genre: "history", Count: 1, Needed: 2},
+		{Genre: "fantasy", Count: 5, Needed: 0},
+		{Genre: "scifi", Count: 3, Needed: 0},
+	}
+	if body.Target != 3 || !reflect.DeepEqual(body.Genres, want) {
+		t.Fatalf("coverage = %+v, want %+v", body, want)
+	}
+
+	expectStatus(t, serve(router, http.MethodGet, "/books/coverage?target=0", nil), http.StatusBadRequest)
+}
+
+func TestPublicationSpanOverKnownDates(t *testing.T) {
+	h, router := newTestHandler(t)
+	date := func(y int, m time.Month, d int) *time.Time {
+		at := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &at
+	}
+	type span struct {
+		Earliest *datedTitle `json:"earliest"`
+		Latest   *datedTitle `json:"latest"`
+	}
+	get := func() span {
+		t.Helper()
+		w := serve(router, http.MethodGet, "/books/publication-span", nil)
+		expectStatus(t, w, http.StatusOK)
+		var s span
+		decodeBody(t, w, &s)
+		return s
+	}
+
+	if s := get(); s.Earliest != nil || s.Latest != nil {
+		t.Fatalf("empty catalog span = %+v, want nulls", s)
+	}
+
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", PublishedAt: date(1965, time.August, 1)},
+		Book{Title: "Emma", Author: "Jane Austen", PublishedAt: date(1815, time.December, 23)},
+		Book{Title: "Hyperion", Author: "Dan Simmons", PublishedAt: date(1989, time.May, 26)},
+		// Undated and deleted books are left out.
+		Book{Title: "Undated", Author: "Anon"},
+		Book{Title: "Deleted", Author: "Anon", PublishedAt: date(2020, time.January, 1), DeletedAt: date(2024, time.January, 1)},
+	)
+	want := span{
+		Earliest: &datedTitle{PublishedAt: *date(1815, time.December, 23), Title: "Emma"},
+		Latest:   &datedTitle{PublishedAt: *date(1989, time.May, 26), Title: "Hyperion"},
+	}
+	if s := get(); !reflect.DeepEqual(s, want) {
+		t.Fatalf("span = {%+v %+v}, want {%+v %+v}", s.Earliest, s.Latest, want.Earliest, want.Latest)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Review eviction policies for Config.ReviewEviction.
+const (
+	evictOldest = "oldest"
+	evictLowest = "lowest"
+)
+
+type Review struct {
+	Reviewer  string    `json:"reviewer" bson:"reviewer"`
+	Rating    int       `json:"rating" bson:"rating"`
+	Comment   string    `json:"comment,omitempty" bson:"comment,omitempty"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+func (r Review) validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(r.Reviewer) == "" {
+		errs = append(errs, FieldError{Field: "reviewer", Message: "is required"})
+	}
+	if r.Rating < 1 || r.Rating > 5 {
+		errs = append(errs, FieldError{Field: "rating", Message: "must be between 1 and 5"})
+	}
+	return errs
+}
+
+// Add a review, keeping at most MaxReviews and refreshing the rating aggregates
+func (h *BookHandler) addReview(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var review Review
+	if err := c.ShouldBindJSON(&review); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errs := review.validate(); len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": errs})
+		return
+	}
+	review.CreatedAt = h.now().UTC()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	result, err := h.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objID, "deletedAt": notDeleted},
+		bson.D{{Key: "$push", Value: bson.M{"reviews": h.reviewPush(review)}}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding review"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		}
+		return
+	}
+
+	// Recompute from whatever survived the $slice, not from the pushed review.
+	var book Book
+	err = h.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": objID},
+		mongo.Pipeline{{{Key: "$set", Value: bson.D{
+			{Key: "averageRating", Value: bson.D{{Key: "$avg", Value: "$reviews.rating"}}},
+			{Key: "reviewCount", Value: bson.D{{Key: "$size", Value: "$reviews"}}},
+		}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&book)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating rating"})
+		return
+	}
+	h.bumpGeneration(ctx)
+
+	h.renderBook(c, http.StatusCreated, book)
+}
+
+// reviewPush builds the $push modifier that appends review and trims the array
+// to MaxReviews according to the configured eviction policy.
+func (h *BookHandler) reviewPush(review Review) bson.D {
+	if h.config.ReviewEviction == evictLowest {
+		// Keep the best-rated reviews, preferring newer ones on equal rating.
+		return bson.D{
+			{Key: "$each", Value: bson.A{review}},
+			{Key: "$sort", Value: bson.D{{Key: "rating", Value: -1}, {Key: "createdAt", Value: -1}}},
+			{Key: "$slice", Value: h.config.MaxReviews},
+		}
+	}
+	// Reviews are appended in order, so the oldest sit at the front.
+	return bson.D{
+		{Key: "$each", Value: bson.A{review}},
+		{Key: "$slice", Value: -h.config.MaxReviews},
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestReviewsOverTheCapKeepTheRetainedAverage(t *testing.T) {
+	for _, tt := range []struct {
+		eviction string
+		push     []int
+		want     []int
+	}{
+		{evictOldest, []int{1, 2, 3, 5}, []int{2, 3, 5}},
+		{evictLowest, []int{5, 1, 4, 2}, []int{5, 4, 2}},
+	} {
+		t.Run(tt.eviction, func(t *testing.T) {
+			h, router := newTestHandler(t, func(cfg *Config) {
+				cfg.MaxReviews = 3
+				cfg.ReviewEviction = tt.eviction
+			})
+			ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert"})
+
+			var book Book
+			for i, rating := range tt.push {
+				w := serve(router, http.MethodPost, "/books/"+ids[0].Hex()+"/reviews", Review{Reviewer: fmt.Sprint("r", i), Rating: rating})
+				expectStatus(t, w, http.StatusCreated)
+				book = Book{}
+				decodeBody(t, w, &book)
+			}
+
+			var got []int
+			sum := 0
+			for _, review := range book.Reviews {
+				got = append(got, review.Rating)
+				sum += review.Rating
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("retained ratings = %v, want %v", got, tt.want)
+			}
+			wantAverage := float64(sum) / float64(len(tt.want))
+			if book.ReviewCount != len(tt.want) || math.Abs(book.AverageRating-wantAverage) > 1e-9 {
+				t.Fatalf("reviewCount = %d, averageRating = %v, want %d and %v", book.ReviewCount, book.AverageRating, len(tt.want), wantAverage)
+			}
+		})
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sale is one sell event, stored in the sales collection.
+type Sale struct {
+	ID       primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	BookID   primitive.ObjectID `json:"bookId" bson:"bookId"`
+	Quantity int                `json:"quantity" bson:"quantity"`
+	SoldAt   time.Time          `json:"soldAt" bson:"soldAt"`
+	// OrderID groups the sales of one checkout, for co-purchase analysis.
+	OrderID string `json:"orderId,omitempty" bson:"orderId,omitempty"`
+	// Location is the branch the copies were sold from, when given.
+	Location string `json:"location,omitempty" bson:"location,omitempty"`
+}
+
+type quantityRequest struct {
+	Quantity int    `json:"quantity"`
+	OrderID  string `json:"orderId"` // sell only
+}
+
+// bindQuantity decodes a {"quantity": n} body, which must be positive.
+func bindQuantity(c *gin.Context) (quantityRequest, bool) {
+	var req quantityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	if req.Quantity < 1 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Validation failed",
+			"fields": []FieldError{{Field: "quantity", Message: "must be at least 1"}},
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// Sell copies of a book, failing with 409 if there is not enough stock
+func (h *BookHandler) sellBook(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	req, ok := bindQuantity(c)
+	if !ok {
+		return
+	}
+	quantity := req.Quantity
+	location, ok := locationParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": objID, "deletedAt": notDeleted, "stock": bson.M{"$gte": quantity}}
+	inc := bson.M{"stock": -quantity}
+	if location != "" {
+		filter["stockByLocation."+location] = bson.M{"$gte": quantity}
+		inc["stockByLocation."+location] = -quantity
+	} else {
+		filter["$expr"] = unassignedCovers(quantity)
+	}
+
+	// The decrement and the sale record commit together, so stock never
+	// drops without a sale to show for it.
+	var book Book
+	err = h.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		err := h.collection.FindOneAndUpdate(
+			sc,
+			filter,
+			bson.D{{Key: "$inc", Value: inc}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&book)
+		if err != nil {
+			return err
+		}
+		sale := Sale{BookID: objID, Quantity: quantity, SoldAt: h.now().UTC(), OrderID: req.OrderID, Location: location}
+		if _, err := h.sales.InsertOne(sc, sale); err != nil {
+			return fmt.Errorf("recording sale: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		h.stockMissReply(ctx, c, objID)
+		return
+	case isWriteConflict(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock changed while selling, try again"})
+		return
+	case err != nil:
+		log.Printf("sell %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating stock"})
+		return
+	}
+	h.bumpGeneration(ctx)
+
+	h.scopeBook(c, &book)
+	c.JSON(http.StatusOK, book)
+}
+
+// Add copies of a book to stock
+func (h *BookHandler) restockBook(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	req, ok := bindQuantity(c)
+	if !ok {
+		return
+	}
+	quantity := req.Quantity
+	location, ok := locationParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	inc := bson.M{"stock": quantity}
+	if location != "" {
+		inc["stockByLocation."+location] = quantity
+	}
+
+	var book Book
+	err = h.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": objID, "deletedAt": notDeleted},
+		bson.D{{Key: "$inc", Value: inc}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&book)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		}
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating stock"})
+		return
+	}
+	h.bumpGeneration(ctx)
+
+	h.scopeBook(c, &book)
+	c.JSON(http.StatusOK, book)
+}
+
+// unassignedCovers is a $expr matching books whose stock outside every
+// location still covers quantity, so a sale without ?location= never takes
+// copies a branch holds and Stock stays at least the sum of StockByLocation.
+func unassignedCovers(quantity int) bson.M {
+	return bson.M{"$gte": bson.A{bson.M{"$subtract": bson.A{bson.M{"$ifNull": bson.A{"$stock", 0}}, assignedStock}}, quantity}}
+}
+
+// assignedStock sums a book's StockByLocation, 0 when it has none.
+var assignedStock = bson.M{"$sum": bson.M{"$map": bson.M{
+	"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$stockByLocation", bson.M{}}}},
+	"in":    "$$this.v",
+}}}
+
+// locationParam reads the optional ?location= that scopes a sell or restock
+// to one branch's stock.
+func locationParam(c *gin.Context) (string, bool) {
+	location := c.Query("location")
+	if location != "" && !validLocationKey(location) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location"})
+		return "", false
+	}
+	return location, true
+}
+
+type availability struct {
+	Locations  map[string]int `json:"locations"`
+	Unassigned int            `json:"unassigned"`
+	Total      int            `json:"total"`
+}
+
+// Get a book's stock per location alongside the total
+func (h *BookHandler) getAvailability(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	var book Book
+	err = h.reader(c).FindOne(
+		ctx,
+		bson.M{"_id": objID, "deletedAt": notDeleted},
+		options.FindOne().SetProjection(bson.M{"stock": 1, "stockByLocation": 1}),
+	).Decode(&book)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		}
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving book"})
+		return
+	}
+
+	result := availability{Locations: make(map[string]int), Total: book.Stock}
+	for location, stock := range book.StockByLocation {
+		result.Locations[location] = stock
+	}
+	result.Unassigned = book.unassignedStock()
+
+	render(c, http.StatusOK, result)
+}
+
+type cartItem struct {
+	ID  string `json:"id"`
+	Qty int    `json:"qty"`
+}
+
+// cartShortage names the cart item that could not be reserved.
+type cartShortage struct {
+	item      cartItem
+	found     bool
+	available int
+}
+
+func (e cartShortage) Error() string {
+	return fmt.Sprintf("cannot reserve %d of %s", e.item.Qty, e.item.ID)
+}
+
+// Reserve stock for every item of a cart, [{"id": ..., "qty": n}, ...], in
+// one transaction: either every item is decremented or none is
+func (h *BookHandler) reserveCart(c *gin.Context) {
+	var items []cartItem
+	if err := c.ShouldBindJSON(&items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(items) == 0 || len(items) > maxBatchIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cart must hold between 1 and 100 items"})
+		return
+	}
+
+	// The same book listed twice is reserved once for the summed quantity.
+	var errs []FieldError
+	cart := make([]cartItem, 0, len(items))
+	index := make(map[primitive.ObjectID]int, len(items))
+	objIDs := make([]primitive.ObjectID, 0, len(items))
+	for i, item := range items {
+		objID, err := primitive.ObjectIDFromHex(item.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID", "id": item.ID})
+			return
+		}
+		if item.Qty < 1 {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("[%d].qty", i), Message: "must be at least 1"})
+			continue
+		}
+		if j, ok := index[objID]; ok {
+			cart[j].Qty += item.Qty
+			continue
+		}
+		index[objID] = len(cart)
+		cart = append(cart, cartItem{ID: objID.Hex(), Qty: item.Qty})
+		objIDs = append(objIDs, objID)
+	}
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": errs})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	err := h.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		for i, objID := range objIDs {
+			qty := cart[i].Qty
+			result, err := h.collection.UpdateOne(
+				sc,
+				bson.M{"_id": objID, "deletedAt": notDeleted, "stock": bson.M{"$gte": qty}, "$expr": unassignedCovers(qty)},
+				bson.D{{Key: "$inc", Value: bson.M{"stock": -qty}}},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 1 {
+				continue
+			}
+
+			shortage := cartShortage{item: cart[i]}
+			var book Book
+			err = h.collection.FindOne(
+				sc,
+				bson.M{"_id": objID, "deletedAt": notDeleted},
+				options.FindOne().SetProjection(bson.M{"stock": 1, "stockByLocation": 1}),
+			).Decode(&book)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+			shortage.found, shortage.available = err == nil, book.unassignedStock()
+			return shortage
+		}
+		return nil
+	})
+
+	var shortage cartShortage
+	switch {
+	case errors.As(err, &shortage) && !shortage.found:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Book not found", "item": shortage.item})
+	case errors.As(err, &shortage):
+		c.JSON(http.StatusConflict, gin.H{"error": "Insufficient stock", "item": shortage.item, "available": shortage.available})
+	case isWriteConflict(err):
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock changed while reserving, try again"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reserving stock"})
+	default:
+		h.bumpGeneration(ctx)
+		c.JSON(http.StatusOK, gin.H{"reserved": cart})
+	}
+}
+
+// stockMissReply explains why a conditional stock decrement matched nothing:
+// either the book does not exist (404) or it is short of stock (409).
+func (h *BookHandler) stockMissReply(ctx context.Context, c *gin.Context, objID primitive.ObjectID) {
+	count, err := h.collection.CountDocuments(ctx, bson.M{"_id": objID, "deletedAt": notDeleted})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating stock"})
+		return
+	}
+	if count == 0 {
+		if !h.goneReply(ctx, c, objID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found"})
+		}
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Insufficient stock"})
+}
+
+type reorderAlert struct {
+	Book      Book `json:"book" bson:"book"`
+	Shortfall int  `json:"shortfall" bson:"shortfall"`
+}
+
+// Get in-print books at or below their own reorder point, furthest below first
+func (h *BookHandler) getBooksBelowReorder(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// stock is omitted when zero, so treat a missing value as 0.
+	stock := bson.M{"$ifNull": bson.A{"$stock", 0}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"deletedAt":    notDeleted,
+			"outOfPrint":   bson.M{"$ne": true},
+			"reorderPoint": bson.M{"$gt": 0},
+			"$expr":        bson.M{"$lte": bson.A{stock, "$reorderPoint"}},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"book":      "$$ROOT",
+			"shortfall": bson.M{"$subtract": bson.A{"$reorderPoint", stock}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "shortfall", Value: -1}, {Key: "book.title", Value: 1}}}},
+	}
+
+	cursor, err := h.reader(c).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	alerts := make([]reorderAlert, 0)
+	if err := cursor.All(ctx, &alerts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+
+	for i := range alerts {
+		h.presentBook(c, &alerts[i].Book)
+	}
+	render(c, http.StatusOK, alerts)
+}
+
+type restockSuggestion struct {
+	Book           Book    `json:"book"`
+	DailySales     float64 `json:"dailySales"`
+	DaysToStockout float64 `json:"daysToStockout"`
+}
+
+// Suggest books to reorder, most urgent first, from recent sales velocity
+func (h *BookHandler) getRestockSuggestions(c *gin.Context) {
+	threshold := h.config.RestockThresholdDays
+	if raw := c.Query("days"); raw != "" {
+		days, err := strconv.ParseFloat(raw, 64)
+		if err != nil || days <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive number"})
+			return
+		}
+		threshold = days
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	since := h.now().Add(-h.config.RestockWindow)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"soldAt": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$bookId"},
+			{Key: "sold", Value: bson.D{{Key: "$sum", Value: "$quantity"}}},
+		}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: h.collection.Name()},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "book"},
+		}}},
+		{{Key: "$unwind", Value: "$book"}},
+		// Discontinued books are not reordered, as in the listing.
+		{{Key: "$match", Value: bson.M{"book.deletedAt": notDeleted, "book.outOfPrint": bson.M{"$ne": true}}}},
+	}
+
+	cursor, err := h.readerOf(c, h.sales).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing suggestions"})
+		return
+	}
+	var rows []struct {
+		Sold int  `bson:"sold"`
+		Book Book `bson:"book"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error computing suggestions"})
+		return
+	}
+
+	windowDays := h.config.RestockWindow.Hours() / 24
+	suggestions := make([]restockSuggestion, 0)
+	for _, row := range rows {
+		daily := float64(row.Sold) / windowDays
+		if daily <= 0 {
+			continue
+		}
+		days := float64(row.Book.Stock) / daily
+		if days < threshold {
+			h.presentBook(c, &row.Book)
+			suggestions = append(suggestions, restockSuggestion{Book: row.Book, DailySales: daily, DaysToStockout: days})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].DaysToStockout < suggestions[j].DaysToStockout
+	})
+
+	render(c, http.StatusOK, suggestions)
+}
+
+type coPurchase struct {
+	Book   Book `json:"book" bson:"book"`
+	Orders int  `json:"orders" bson:"orders"`
+}
+
+// Get the books most often sold in the same orders as this one
+func (h *BookHandler) getFrequentlyBoughtWith(c *gin.Context) {
+	id := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	limit := 5
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	orders, err := h.readerOf(c, h.sales).Distinct(ctx, "orderId", bson.M{"bookId": objID, "orderId": bson.M{"$exists": true}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading sales"})
+		return
+	}
+	related := make([]coPurchase, 0)
+	if len(orders) == 0 {
+		render(c, http.StatusOK, related)
+		return
+	}
+
+	// Rank the other books by how many of those orders they appear in.
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"orderId": bson.M{"$in": orders}, "bookId": bson.M{"$ne": objID}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$bookId"},
+			{Key: "orderIds", Value: bson.D{{Key: "$addToSet", Value: "$orderId"}}},
+		}}},
+		{{Key: "$project", Value: bson.M{"orders": bson.M{"$size": "$orderIds"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "orders", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: h.collection.Name()},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "book"},
+		}}},
+		{{Key: "$unwind", Value: "$book"}},
+		{{Key: "$match", Value: bson.M{"book.deletedAt": notDeleted}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := h.readerOf(c, h.sales).Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading sales"})
+		return
+	}
+	if err := cursor.All(ctx, &related); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading sales"})
+		return
+	}
+
+	for i := range related {
+		h.presentBook(c, &related[i].Book)
+	}
+	render(c, http.StatusOK, related)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSellWithoutLocationOnlyTakesUnassignedStock(t *testing.T) {
+	requireReplicaSet(t)
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Stock: 10, StockByLocation: map[string]int{"north": 6, "south": 3}})
+	sell := "/books/" + ids[0].Hex() + "/sell"
+
+	// Only one copy is unassigned, so an unscoped sale of two must not dip
+	// into a branch.
+	expectStatus(t, serve(router, http.MethodPost, sell, quantityRequest{Quantity: 2}), http.StatusConflict)
+	if got := findBook(t, h, ids[0]); got.Stock != 10 {
+		t.Fatalf("stock after refused sale = %d, want 10", got.Stock)
+	}
+
+	expectStatus(t, serve(router, http.MethodPost, sell+"?location=north", quantityRequest{Quantity: 2}), http.StatusOK)
+	expectStatus(t, serve(router, http.MethodPost, sell, quantityRequest{Quantity: 1}), http.StatusOK)
+	expectStatus(t, serve(router, http.MethodPost, sell+"?location=south", quantityRequest{Quantity: 4}), http.StatusConflict)
+
+	got := findBook(t, h, ids[0])
+	if got.Stock != 7 || got.StockByLocation["north"] != 4 || got.StockByLocation["south"] != 3 {
+		t.Fatalf("stock = %d by location %v, want 7 with north 4 and south 3", got.Stock, got.StockByLocation)
+	}
+
+	w := serve(router, http.MethodGet, "/books/"+ids[0].Hex()+"/availability", nil)
+	expectStatus(t, w, http.StatusOK)
+	var avail availability
+	decodeBody(t, w, &avail)
+	if avail.Unassigned != 0 || avail.Total != 7 {
+		t.Fatalf("availability = %+v, want 0 unassigned of 7", avail)
+	}
+}
+
+func TestSellDecrementsStockOnlyWithASaleRecorded(t *testing.T) {
+	requireReplicaSet(t)
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.TombstoneRetention = time.Hour })
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Stock: 5},
+		Book{Title: "Emma", Author: "Jane Austen", Stock: 5},
+	)
+	// Reusing an order ID makes the second sale's record fail to insert.
+	_, err := h.sales.Indexes().CreateOne(testContext(t), mongo.IndexModel{
+		Keys:    bson.D{{Key: "orderId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sell := "/books/" + ids[0].Hex() + "/sell"
+
+	expectStatus(t, serve(router, http.MethodPost, sell, quantityRequest{Quantity: 2, OrderID: "o1"}), http.StatusOK)
+	expectStatus(t, serve(router, http.MethodPost, sell, quantityRequest{Quantity: 1, OrderID: "o1"}), http.StatusInternalServerError)
+	if got := findBook(t, h, ids[0]).Stock; got != 3 {
+		t.Fatalf("stock = %d, want 3: the unrecorded sale must not take copies", got)
+	}
+	if n, err := h.sales.CountDocuments(testContext(t), bson.M{"bookId": ids[0]}); err != nil || n != 1 {
+		t.Fatalf("sales recorded = %d (%v), want 1", n, err)
+	}
+
+	// Deleted and unknown books.
+	emma := "/books/" + ids[1].Hex()
+	expectStatus(t, serve(router, http.MethodDelete, emma, nil), http.StatusOK)
+	expectStatus(t, serve(router, http.MethodPost, emma+"/sell", quantityRequest{Quantity: 1}), http.StatusGone)
+	expectStatus(t, serve(router, http.MethodPost, "/books/"+primitive.NewObjectID().Hex()+"/sell", quantityRequest{Quantity: 1}), http.StatusNotFound)
+}
+
+func TestReserveCartWithOneShortItemReservesNothing(t *testing.T) {
+	requireReplicaSet(t)
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Stock: 5},
+		// Four copies in stock, but three of them are held by a branch.
+		Book{Title: "Emma", Author: "Jane Austen", Stock: 4, StockByLocation: map[string]int{"north": 3}},
+	)
+
+	cart := []cartItem{{ID: ids[0].Hex(), Qty: 2}, {ID: ids[1].Hex(), Qty: 2}}
+	w := serve(router, http.MethodPost, "/books/reserve-cart", cart)
+	expectStatus(t, w, http.StatusConflict)
+	var body struct {
+		Item      cartItem `json:"item"`
+		Available int      `json:"available"`
+	}
+	decodeBody(t, w, &body)
+	if body.Item.ID != ids[1].Hex() || body.Available != 1 {
+		t.Fatalf("conflict = %+v, want Emma with 1 available", body)
+	}
+	for i, want := range []int{5, 4} {
+		if got := findBook(t, h, ids[i]); got.Stock != want {
+			t.Fatalf("%s stock = %d, want %d untouched", got.Title, got.Stock, want)
+		}
+	}
+
+	cart[1].Qty = 1
+	expectStatus(t, serve(router, http.MethodPost, "/books/reserve-cart", cart), http.StatusOK)
+	if got := findBook(t, h, ids[0]); got.Stock != 3 {
+		t.Fatalf("Dune stock = %d, want 3", got.Stock)
+	}
+}
+
+func TestBelowReorderUsesPatchedReorderPoints(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Stock: 1},
+		Book{Title: "Emma", Author: "Jane Austen", Stock: 5},
+		Book{Title: "Ulysses", Author: "James Joyce", Stock: 9},
+	)
+	patch := func(id int, body string) *httptest.ResponseRecorder {
+		return serve(router, http.MethodPatch, "/books/"+ids[id].Hex(), body)
+	}
+	expectStatus(t, patch(0, `{"reorderPoint": 3}`), http.StatusOK)
+	expectStatus(t, patch(1, `{"reorderPoint": 6}`), http.StatusOK)
+	expectStatus(t, patch(2, `{"reorderPoint": 4}`), http.StatusOK)
+	expectStatus(t, patch(2, `{"reorderPoint": -1}`), http.StatusUnprocessableEntity)
+
+	w := serve(router, http.MethodGet, "/books/below-reorder", nil)
+	expectStatus(t, w, http.StatusOK)
+	var alerts []reorderAlert
+	decodeBody(t, w, &alerts)
+	var got []string
+	for _, alert := range alerts {
+		got = append(got, fmt.Sprintf("%s:%d", alert.Book.Title, alert.Shortfall))
+	}
+	if want := []string{"Dune:2", "Emma:1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("below reorder = %v, want %v", got, want)
+	}
+}
+
+func TestRestockSuggestionsMostUrgentFirst(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) {
+		cfg.RestockWindow = 10 * 24 * time.Hour
+		cfg.RestockThresholdDays = 30
+	})
+	ids := insertBooks(t, h,
+		Book{Title: "Slow", Author: "A", Stock: 10},
+		Book{Title: "Fast", Author: "B", Stock: 10},
+		Book{Title: "Plenty", Author: "C", Stock: 100},
+		Book{Title: "Stale", Author: "D", Stock: 1},
+		Book{Title: "Dropped", Author: "E", Stock: 1, OutOfPrint: true},
+	)
+	now := time.Now()
+	sales := []interface{}{
+		Sale{BookID: ids[0], Quantity: 5, SoldAt: now.Add(-48 * time.Hour)},
+		Sale{BookID: ids[1], Quantity: 12, SoldAt: now.Add(-24 * time.Hour)},
+		Sale{BookID: ids[1], Quantity: 8, SoldAt: now.Add(-72 * time.Hour)},
+		Sale{BookID: ids[2], Quantity: 1, SoldAt: now.Add(-24 * time.Hour)},
+		// Outside the window, so it says nothing about current velocity.
+		Sale{BookID: ids[3], Quantity: 50, SoldAt: now.Add(-30 * 24 * time.Hour)},
+		// Selling fast, but out of print, so not to be reordered.
+		Sale{BookID: ids[4], Quantity: 10, SoldAt: now.Add(-24 * time.Hour)},
+	}
+	if _, err := h.sales.InsertMany(testContext(t), sales); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(router, http.MethodGet, "/books/restock-suggestions", nil)
+	expectStatus(t, w, http.StatusOK)
+	var suggestions []restockSuggestion
+	decodeBody(t, w, &suggestions)
+	var got []string
+	for _, s := range suggestions {
+		got = append(got, fmt.Sprintf("%s:%.0f", s.Book.Title, s.DaysToStockout))
+	}
+	// Fast sells 2 a day (5 days left), Slow 0.5 a day (20 days left) and
+	// Plenty 0.1 a day (1000 days, past the threshold).
+	if want := []string{"Fast:5", "Slow:20"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("suggestions = %v, want %v", got, want)
+	}
+}
+
+func TestFrequentlyBoughtWithRanksByCoOccurrence(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert"},
+		Book{Title: "Dune Messiah", Author: "Frank Herbert"},
+		Book{Title: "Emma", Author: "Jane Austen"},
+		Book{Title: "Hyperion", Author: "Dan Simmons"},
+		Book{Title: "Ulysses", Author: "James Joyce"},
+	)
+	orders := map[string][]int{
+		"o1": {0, 1, 2},
+		// Two lines for one book still count as one order.
+		"o2": {0, 1, 1},
+		"o3": {0, 1, 3},
+		"o4": {0, 3},
+		// Orders without Dune say nothing about what goes with it.
+		"o5": {2, 3, 4},
+		"o6": {2, 4},
+	}
+	var sales []interface{}
+	for order, books := range orders {
+		for _, i := range books {
+			sales = append(sales, Sale{BookID: ids[i], Quantity: 1, SoldAt: time.Now(), OrderID: order})
+		}
+	}
+	if _, err := h.sales.InsertMany(testContext(t), sales); err != nil {
+		t.Fatal(err)
+	}
+
+	related := func(id primitive.ObjectID, query string) []string {
+		t.Helper()
+		w := serve(router, http.MethodGet, "/books/"+id.Hex()+"/frequently-bought-with"+query, nil)
+		expectStatus(t, w, http.StatusOK)
+		var got []coPurchase
+		decodeBody(t, w, &got)
+		out := []string{}
+		for _, p := range got {
+			out = append(out, fmt.Sprintf("%s:%d", p.Book.Title, p.Orders))
+		}
+		return out
+	}
+
+	if got, want := related(ids[0], ""), []string{"Dune Messiah:3", "Hyperion:2", "Emma:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with Dune = %v, want %v", got, want)
+	}
+	if got, want := related(ids[0], "?limit=1"), []string{"Dune Messiah:3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with Dune, limit 1 = %v, want %v", got, want)
+	}
+	unsold := insertBooks(t, h, Book{Title: "Unsold", Author: "Nobody"})
+	if got := related(unsold[0], ""); len(got) != 0 {
+		t.Errorf("with a book never sold = %v, want none", got)
+	}
+}
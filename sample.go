@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultSampleSize = 10
+	maxSampleSize     = 100
+)
+
+// Get a pseudo-random subset of the filtered listing that is the same for
+// the same ?seed= as long as the catalog does not change
+func (h *BookHandler) getSample(c *gin.Context) {
+	n := defaultSampleSize
+	if raw := c.Query("n"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxSampleSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "n must be between 1 and 100"})
+			return
+		}
+		n = parsed
+	}
+	seed := c.Query("seed")
+	if seed == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seed is required"})
+		return
+	}
+	filter, _, err := h.listQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	// Candidates come back in _id order so the shuffle input is stable.
+	cursor, err := h.reader(c).Find(ctx, filter, options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	var candidates []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &candidates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+
+	ids := make([]primitive.ObjectID, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.ID
+	}
+	rng := rand.New(rand.NewSource(seedValue(seed)))
+	rng.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+
+	found, err := h.findBooksIn(ctx, h.reader(c), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	byID := make(map[primitive.ObjectID]Book, len(found))
+	for _, book := range found {
+		byID[book.ID] = book
+	}
+	books := make([]Book, 0, len(ids))
+	for _, id := range ids {
+		if book, ok := byID[id]; ok {
+			book.Reviews = nil
+			books = append(books, book)
+		}
+	}
+
+	h.renderBooks(c, http.StatusOK, books)
+}
+
+// seedValue turns any ?seed= string, such as a user ID, into a PRNG seed.
+func seedValue(seed string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+	return int64(hash.Sum64())
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSampleIsStablePerSeed(t *testing.T) {
+	h, router := newTestHandler(t)
+	books := make([]Book, 30)
+	for i := range books {
+		books[i] = Book{Title: fmt.Sprintf("Book %02d", i), Author: "A"}
+	}
+	insertBooks(t, h, books...)
+
+	sample := func(query string) []string {
+		t.Helper()
+		w := serve(router, http.MethodGet, "/books/sample?"+query, nil)
+		expectStatus(t, w, http.StatusOK)
+		var got []Book
+		decodeBody(t, w, &got)
+		return titles(got)
+	}
+
+	first := sample("n=5&seed=user-1")
+	if len(first) != 5 {
+		t.Fatalf("sample = %v, want 5 books", first)
+	}
+	if again := sample("n=5&seed=user-1"); !reflect.DeepEqual(again, first) {
+		t.Fatalf("same seed gave %v, then %v", first, again)
+	}
+	// With 30 books, two seeds picking the same five in the same order would
+	// take a collision far less likely than anything else going wrong.
+	if other := sample("n=5&seed=user-2"); reflect.DeepEqual(other, first) {
+		t.Fatalf("seeds user-1 and user-2 both gave %v", first)
+	}
+
+	// A sample of everything is a shuffle of the whole catalog.
+	all := sample("n=30&seed=user-1")
+	if reflect.DeepEqual(all, titles(books)) {
+		t.Fatalf("n=30 came back unshuffled: %v", all)
+	}
+	slices.Sort(all)
+	if !reflect.DeepEqual(all, titles(books)) {
+		t.Fatalf("n=30 = %v, want every book once", all)
+	}
+
+	expectStatus(t, serve(router, http.MethodGet, "/books/sample?n=5", nil), http.StatusBadRequest)
+}
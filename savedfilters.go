@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SavedFilter is a named set of listing parameters, applied with
+// GET /books?saved_filter=<name>. Comma-separated values work as in the query.
+type SavedFilter struct {
+	Name      string            `json:"name" bson:"_id"`
+	Params    map[string]string `json:"params" bson:"params"`
+	CreatedAt time.Time         `json:"createdAt" bson:"createdAt"`
+}
+
+var savedFilterName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// savedFilter loads a saved filter by name.
+func (h *BookHandler) savedFilter(ctx context.Context, name string) (SavedFilter, error) {
+	var saved SavedFilter
+	err := h.savedFilters.FindOne(ctx, bson.M{"_id": name}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return saved, fmt.Errorf("unknown saved filter %q", name)
+	}
+	if err != nil {
+		return saved, fmt.Errorf("loading saved filter %q: %v", name, err)
+	}
+	return saved, nil
+}
+
+// Save a named listing filter, replacing any previous one of that name
+func (h *BookHandler) saveFilter(c *gin.Context) {
+	var saved SavedFilter
+	if err := c.ShouldBindJSON(&saved); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var errs []FieldError
+	if !savedFilterName.MatchString(saved.Name) {
+		errs = append(errs, FieldError{Field: "name", Message: "must be 1-64 letters, digits, '-' or '_'"})
+	}
+	query := url.Values{}
+	for param, value := range saved.Params {
+		if !listParams[param] {
+			errs = append(errs, FieldError{Field: "params." + param, Message: "is not a listing parameter"})
+			continue
+		}
+		query.Set(param, value)
+	}
+	if len(errs) == 0 {
+		if _, _, err := h.listFilter(query); err != nil {
+			errs = append(errs, FieldError{Field: "params", Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": errs})
+		return
+	}
+	if saved.Params == nil {
+		saved.Params = map[string]string{}
+	}
+	saved.CreatedAt = h.now().UTC()
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	_, err := h.savedFilters.ReplaceOne(ctx, bson.M{"_id": saved.Name}, saved, options.Replace().SetUpsert(true))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving filter"})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// Get every saved filter by name
+func (h *BookHandler) getSavedFilters(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	cursor, err := h.savedFilters.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving saved filters"})
+		return
+	}
+	saved := make([]SavedFilter, 0)
+	if err := cursor.All(ctx, &saved); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving saved filters"})
+		return
+	}
+
+	render(c, http.StatusOK, saved)
+}
+
+// Delete a saved filter by name
+func (h *BookHandler) deleteSavedFilter(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	result, err := h.savedFilters.DeleteOne(ctx, bson.M{"_id": c.Param("name")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting saved filter"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved filter not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved filter deleted"})
+}
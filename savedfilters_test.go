@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSavedFilterListsMatchingBooks(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99, Position: 2},
+		Book{Title: "Dune Messiah", Author: "Frank Herbert", Price: 8.5, Position: 1},
+		Book{Title: "Dune (Deluxe)", Author: "Frank Herbert", Price: 60},
+		Book{Title: "Emma", Author: "Jane Austen", Price: 5},
+	)
+
+	saved := SavedFilter{Name: "cheap-dune", Params: map[string]string{"search": "dune", "tier": "budget", "sort": "title"}}
+	expectStatus(t, serve(router, http.MethodPost, "/books/saved-filters", saved), http.StatusOK)
+
+	w := serve(router, http.MethodGet, "/books/saved-filters", nil)
+	expectStatus(t, w, http.StatusOK)
+	var listed []SavedFilter
+	decodeBody(t, w, &listed)
+	if len(listed) != 1 || listed[0].Name != saved.Name || !reflect.DeepEqual(listed[0].Params, saved.Params) {
+		t.Fatalf("saved filters = %+v, want just %s", listed, saved.Name)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"?saved_filter=cheap-dune", []string{"Dune", "Dune Messiah"}},
+		// Request parameters override the saved ones.
+		{"?saved_filter=cheap-dune&sort=position", []string{"Dune Messiah", "Dune"}},
+	} {
+		w := serve(router, http.MethodGet, "/books"+tt.query, nil)
+		expectStatus(t, w, http.StatusOK)
+		var books []Book
+		decodeBody(t, w, &books)
+		if got := titles(books); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("/books%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	w = serve(router, http.MethodPost, "/books/saved-filters", SavedFilter{Name: "bad", Params: map[string]string{"price": "1"}})
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+	expectStatus(t, serve(router, http.MethodGet, "/books?saved_filter=missing", nil), http.StatusBadRequest)
+
+	// A stored filter is checked again when applied.
+	if _, err := h.savedFilters.InsertOne(testContext(t), bson.M{"_id": "stale", "params": bson.M{"price": "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, serve(router, http.MethodGet, "/books?saved_filter=stale", nil), http.StatusBadRequest)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// streamBatchSize keeps cursor batches small so the first results reach
+	// the client before the whole pipeline has run.
+	streamBatchSize = 100
+	// streamFlushEvery is how many lines are written between flushes.
+	streamFlushEvery = 50
+)
+
+// Stream the filtered listing as NDJSON while the aggregation produces it.
+// A failure after the first line is reported as a final {"error": ...} line.
+func (h *BookHandler) streamBooks(c *gin.Context) {
+	filter, opts, err := h.listQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	if opts.Sort != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: opts.Sort}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$set", Value: bson.M{
+			"reviewCount": bson.M{"$size": bson.M{"$ifNull": bson.A{"$reviews", bson.A{}}}},
+		}}},
+		bson.D{{Key: "$unset", Value: bson.A{"reviews", "deletedAt"}}},
+	)
+
+	ctx := c.Request.Context()
+	aggOpts := options.Aggregate().SetBatchSize(streamBatchSize)
+	if opts.Hint != nil {
+		aggOpts.SetHint(opts.Hint)
+	}
+	cursor, err := h.reader(c).Aggregate(ctx, pipeline, aggOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving books"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+
+	fail := func(err error) {
+		log.Printf("stream: %v", err)
+		enc.Encode(gin.H{"error": "Error retrieving books"})
+		c.Writer.Flush()
+	}
+
+	for written := 1; cursor.Next(ctx); written++ {
+		var book Book
+		if err := cursor.Decode(&book); err != nil {
+			fail(err)
+			return
+		}
+		h.presentBook(c, &book)
+		if err := enc.Encode(book); err != nil {
+			log.Printf("stream: write: %v", err)
+			return
+		}
+		if written%streamFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		fail(err)
+		return
+	}
+	c.Writer.Flush()
+}
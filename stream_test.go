@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStreamBooksWritesResultsAsTheCursorProducesThem(t *testing.T) {
+	h, _ := newTestHandler(t)
+	const total = 2*streamBatchSize + streamFlushEvery
+	books := make([]interface{}, total)
+	for i := range books {
+		books[i] = Book{Title: fmt.Sprintf("Book %03d", i), Author: "A"}
+	}
+	if _, err := h.collection.InsertMany(testContext(t), books); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold back every batch after the first: the lines of the first batch
+	// can only reach the client if they were written before the rest ran.
+	router, commands := watchCommands(t, h)
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	defer unblock()
+	commands.hold = func(cmd bson.Raw) {
+		if elems, err := cmd.Elements(); err == nil && len(elems) > 0 && elems[0].Key() == "getMore" {
+			<-release
+		}
+	}
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/books/stream?sort=title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	next := func() (Book, bool) {
+		t.Helper()
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return Book{}, false
+			}
+			var book Book
+			if err := json.Unmarshal([]byte(line), &book); err != nil || book.Title == "" {
+				t.Fatalf("line %q is not a book", line)
+			}
+			return book, true
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the next line")
+			return Book{}, false
+		}
+	}
+
+	for i := range streamBatchSize {
+		book, ok := next()
+		if !ok || book.Title != fmt.Sprintf("Book %03d", i) {
+			t.Fatalf("line %d = %q, want Book %03d before the second batch", i, book.Title, i)
+		}
+	}
+	unblock()
+	n := streamBatchSize
+	for book, ok := next(); ok; book, ok = next() {
+		if want := fmt.Sprintf("Book %03d", n); book.Title != want {
+			t.Fatalf("line %d = %q, want %q", n, book.Title, want)
+		}
+		n++
+	}
+	if n != total {
+		t.Fatalf("streamed %d books, want %d", n, total)
+	}
+}
+
+func TestStreamFailureDoesNotLeakTheDriverError(t *testing.T) {
+	h, router := newTestHandler(t)
+	insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert"})
+	// A title that is not a string fails to decode as a Book.
+	if _, err := h.collection.InsertOne(testContext(t), bson.M{"title": 5, "author": "Broken"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(router, http.MethodGet, "/books/stream?sort=title", nil)
+	expectStatus(t, w, http.StatusOK)
+	var last map[string]interface{}
+	for scanner := bufio.NewScanner(w.Body); scanner.Scan(); {
+		last = nil
+		if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "Error retrieving books"; last["error"] != want {
+		t.Fatalf("last line = %v, want only {\"error\": %q}", last, want)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type searchTagRequest struct {
+	Query string   `json:"q"`
+	Add   []string `json:"add"`
+}
+
+// Tag every book matching a text search
+func (h *BookHandler) tagSearchResults(c *gin.Context) {
+	var req searchTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var errs []FieldError
+	if strings.TrimSpace(req.Query) == "" {
+		errs = append(errs, FieldError{Field: "q", Message: "is required"})
+	}
+	if len(req.Add) == 0 {
+		errs = append(errs, FieldError{Field: "add", Message: "must list at least one tag"})
+	}
+	for _, tag := range req.Add {
+		if strings.TrimSpace(tag) == "" {
+			errs = append(errs, FieldError{Field: "add", Message: "tags must not be blank"})
+			break
+		}
+	}
+	if len(errs) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed", "fields": errs})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.QueryTimeout)
+	defer cancel()
+
+	cursor, err := h.collection.Find(
+		ctx,
+		bson.M{"$text": bson.M{"$search": req.Query}, "deletedAt": notDeleted},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching books"})
+		return
+	}
+	var matches []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &matches); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching books"})
+		return
+	}
+	if len(matches) == 0 {
+		c.JSON(http.StatusOK, gin.H{"matched": 0, "modified": 0})
+		return
+	}
+
+	ids := make([]primitive.ObjectID, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	result, err := h.collection.UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$addToSet": bson.M{"tags": bson.M{"$each": req.Add}}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error tagging books"})
+		return
+	}
+	if result.ModifiedCount > 0 {
+		h.bumpGeneration(ctx)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"matched": len(ids), "modified": result.ModifiedCount})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTagSearchResults(t *testing.T) {
+	h, router := newTestHandler(t)
+	ids := insertBooks(t, h,
+		Book{Title: "Dune", Author: "Frank Herbert", Tags: []string{"classic"}},
+		Book{Title: "Dune Messiah", Author: "Frank Herbert", Tags: []string{"scifi"}},
+		Book{Title: "Emma", Author: "Jane Austen"},
+	)
+
+	w := serve(router, http.MethodPost, "/books/search/tag", `{"q": "dune", "add": ["scifi", "desert"]}`)
+	expectStatus(t, w, http.StatusOK)
+	var counts struct {
+		Matched  int `json:"matched"`
+		Modified int `json:"modified"`
+	}
+	decodeBody(t, w, &counts)
+	if counts.Matched != 2 || counts.Modified != 2 {
+		t.Fatalf("counts = %+v, want 2 matched and 2 modified", counts)
+	}
+
+	for i, want := range [][]string{
+		{"classic", "scifi", "desert"},
+		// A tag the book already has is not added twice.
+		{"scifi", "desert"},
+		nil,
+	} {
+		if got := findBook(t, h, ids[i]).Tags; !reflect.DeepEqual(got, want) {
+			t.Errorf("book %d tags = %v, want %v", i, got, want)
+		}
+	}
+
+	w = serve(router, http.MethodPost, "/books/search/tag", `{"q": "  ", "add": ["scifi"]}`)
+	expectStatus(t, w, http.StatusUnprocessableEntity)
+}
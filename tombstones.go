@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tombstoneTTLIndex expires tombstones at their expiresAt.
+const tombstoneTTLIndex = "expiresAt_ttl"
+
+// tombstone remembers a deleted book's ID so lookups can answer 410 rather
+// than 404 until it expires.
+type tombstone struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	DeletedAt time.Time          `bson:"deletedAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+}
+
+// tombstones is where deleted IDs are kept, next to the books collection.
+func tombstones(collection *mongo.Collection) *mongo.Collection {
+	return collection.Database().Collection("tombstones")
+}
+
+// recordTombstone marks the book as deleted at deletedAt. A soft-deleted book
+// is only purged after SoftDeleteRetention, so its tombstone outlives that by
+// TombstoneRetention. Failures are logged: the delete itself has happened.
+func (h *BookHandler) recordTombstone(ctx context.Context, objID primitive.ObjectID, deletedAt time.Time) {
+	if h.config.TombstoneRetention <= 0 {
+		return
+	}
+	stone := tombstone{
+		ID:        objID,
+		DeletedAt: deletedAt,
+		ExpiresAt: deletedAt.Add(h.config.SoftDeleteRetention + h.config.TombstoneRetention),
+	}
+	_, err := h.tombstones.ReplaceOne(ctx, bson.M{"_id": objID}, stone, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("tombstone for %s: %v", objID.Hex(), err)
+	}
+}
+
+// goneReply answers 410 when the book was deleted within the tombstone
+// window. It reports whether it wrote a response, leaving the caller to send
+// its usual 404 otherwise.
+func (h *BookHandler) goneReply(ctx context.Context, c *gin.Context, objID primitive.ObjectID) bool {
+	if h.config.TombstoneRetention <= 0 {
+		return false
+	}
+	var stone tombstone
+	err := h.tombstones.FindOne(ctx, bson.M{"_id": objID}).Decode(&stone)
+	if err != nil {
+		return false
+	}
+	// The TTL monitor runs about once a minute, so check expiry here too.
+	if !h.now().Before(stone.ExpiresAt) {
+		return false
+	}
+	c.JSON(http.StatusGone, gin.H{"error": "Book has been deleted", "deletedAt": stone.DeletedAt})
+	return true
+}
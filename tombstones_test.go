@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDeletedBooksAreGoneDuringTheTombstoneWindow(t *testing.T) {
+	for _, softDelete := range []time.Duration{0, time.Hour} {
+		h, router := newTestHandler(t, func(cfg *Config) {
+			cfg.SoftDeleteRetention = softDelete
+			cfg.TombstoneRetention = time.Hour
+		})
+		now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+		h.now = func() time.Time { return now }
+		ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert"})
+		path := "/books/" + ids[0].Hex()
+
+		expectStatus(t, serve(router, http.MethodDelete, path, nil), http.StatusOK)
+
+		expectStatus(t, serve(router, http.MethodGet, path, nil), http.StatusGone)
+		expectStatus(t, serve(router, http.MethodPut, path, `{"title": "Dune", "author": "Frank Herbert"}`), http.StatusGone)
+		expectStatus(t, serve(router, http.MethodDelete, path, nil), http.StatusGone)
+		expectStatus(t, serve(router, http.MethodPost, path+"/restock", quantityRequest{Quantity: 1}), http.StatusGone)
+		expectStatus(t, serve(router, http.MethodGet, path+"/availability", nil), http.StatusGone)
+		expectStatus(t, serve(router, http.MethodPost, path+"/reviews", Review{Reviewer: "r", Rating: 5}), http.StatusGone)
+		expectStatus(t, serve(router, http.MethodGet, path+"/price-history", nil), http.StatusGone)
+		// An ID that never existed is still just not found.
+		expectStatus(t, serve(router, http.MethodGet, "/books/"+primitive.NewObjectID().Hex(), nil), http.StatusNotFound)
+
+		// The window runs from the purge, so it ends after both retentions.
+		now = now.Add(softDelete + time.Hour)
+		expectStatus(t, serve(router, http.MethodGet, path, nil), http.StatusNotFound)
+	}
+}
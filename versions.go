@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersionHeader selects the API version of a request and is echoed on
+// the response.
+const apiVersionHeader = "API-Version"
+
+// apiVersionKey is the gin context key holding the resolved version.
+const apiVersionKey = "apiVersion"
+
+// apiVersions are the accepted API versions. Version "1" only exposes the
+// book fields in Config.V1Fields; later versions expose every field.
+var apiVersions = map[string]bool{"1": true, "2": true}
+
+// bookFields are the JSON names of every Book field.
+var bookFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Book{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+// apiVersion resolves the request's API-Version, falling back to
+// DEFAULT_API_VERSION, and rejects unknown versions with 400.
+func (h *BookHandler) apiVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := c.GetHeader(apiVersionHeader)
+		if version == "" {
+			version = h.config.DefaultAPIVersion
+		}
+		if !apiVersions[version] {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unsupported API version " + version})
+			return
+		}
+		c.Set(apiVersionKey, version)
+		c.Header(apiVersionHeader, version)
+		c.Next()
+	}
+}
+
+// versionFields is the set of book fields the request's API version may see,
+// or nil when it sees them all.
+func (h *BookHandler) versionFields(c *gin.Context) map[string]bool {
+	if c.GetString(apiVersionKey) != "1" {
+		return nil
+	}
+	fields := make(map[string]bool, len(h.config.V1Fields))
+	for _, field := range h.config.V1Fields {
+		fields[field] = true
+	}
+	return fields
+}
+
+// bookJSON is Book without its methods, so marshalling it does not recurse.
+type bookJSON Book
+
+// MarshalJSON drops every field outside the book's visible set, when one has
+// been set by presentBook.
+func (b Book) MarshalJSON() ([]byte, error) {
+	if b.visible == nil {
+		return json.Marshal(bookJSON(b))
+	}
+	data, err := json.Marshal(bookJSON(b))
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name := range fields {
+		if !b.visible[name] {
+			delete(fields, name)
+		}
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+func TestV1HidesFieldsItDoesNotKnow(t *testing.T) {
+	h, router := newTestHandler(t, func(cfg *Config) {
+		cfg.V1Fields = []string{"id", "title", "author", "price"}
+		cfg.DefaultAPIVersion = "2"
+	})
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99, Genre: "scifi", WordCount: 188000})
+	path := "/books/" + ids[0].Hex()
+
+	for _, tt := range []struct {
+		version string
+		visible bool
+	}{
+		{"1", false},
+		{"2", true},
+		{"", true},
+	} {
+		var headers []string
+		if tt.version != "" {
+			headers = []string{apiVersionHeader, tt.version}
+		}
+		for _, p := range []string{path, "/books"} {
+			w := serve(router, http.MethodGet, p, nil, headers...)
+			expectStatus(t, w, http.StatusOK)
+			var book map[string]interface{}
+			if p == "/books" {
+				var books []map[string]interface{}
+				decodeBody(t, w, &books)
+				book = books[0]
+			} else {
+				decodeBody(t, w, &book)
+			}
+			if book["title"] != "Dune" {
+				t.Fatalf("version %q %s: title = %v", tt.version, p, book["title"])
+			}
+			for _, field := range []string{"genre", "wordCount", "readingMinutes"} {
+				if _, ok := book[field]; ok != tt.visible {
+					t.Errorf("version %q %s: %s present = %v, want %v", tt.version, p, field, ok, tt.visible)
+				}
+			}
+		}
+	}
+
+	// The allowlist applies to MessagePack responses too.
+	w := serve(router, http.MethodGet, path, nil, apiVersionHeader, "1", "Accept", "application/msgpack")
+	expectStatus(t, w, http.StatusOK)
+	var mh codec.MsgpackHandle
+	mh.RawToString = true
+	var book map[string]interface{}
+	if err := codec.NewDecoderBytes(w.Body.Bytes(), &mh).Decode(&book); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := book["genre"]; ok || book["title"] != "Dune" {
+		t.Fatalf("v1 msgpack = %v, want title without genre", book)
+	}
+
+	expectStatus(t, serve(router, http.MethodGet, path, nil, apiVersionHeader, "9"), http.StatusBadRequest)
+}
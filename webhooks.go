@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook event types.
+const (
+	eventBookCreated = "book.created"
+	eventBookUpdated = "book.updated"
+	eventBookDeleted = "book.deleted"
+)
+
+// WebhookEvent is the body POSTed to every configured webhook URL.
+type WebhookEvent struct {
+	ID         string             `json:"id"`
+	Type       string             `json:"type"`
+	BookID     primitive.ObjectID `json:"bookId"`
+	Book       *Book              `json:"book,omitempty"`
+	OccurredAt time.Time          `json:"occurredAt"`
+}
+
+// newWebhookEvent builds the event for one write, which took the book from
+// before to after (nil for a create or a delete respectively). The ID is
+// derived from that change rather than minted, so a write repeated by a
+// client retry gets the same ID and is deduplicated, while a write restoring
+// an earlier state still differs from the one it undoes.
+func newWebhookEvent(eventType string, bookID primitive.ObjectID, before, after *Book, at time.Time) WebhookEvent {
+	return WebhookEvent{
+		ID:         changeID(eventType, bookID, before, after),
+		Type:       eventType,
+		BookID:     bookID,
+		Book:       after,
+		OccurredAt: at,
+	}
+}
+
+// changeID hashes a write's type, book and the states on either side of it.
+// The states are JSON-encoded because encoding/json, unlike bson, sorts map
+// keys, so equal books always hash the same.
+func changeID(eventType string, bookID primitive.ObjectID, before, after *Book) string {
+	states, err := json.Marshal([]*Book{before, after})
+	if err != nil {
+		// Not expected for a Book; an unshared ID only loses deduplication.
+		return primitive.NewObjectID().Hex()
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00", eventType, bookID.Hex())
+	hash.Write(states)
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// notifyChange sends the event for an update unless it left the book as it
+// was, as a retried PUT or PATCH does: that is not a change to announce.
+func (h *BookHandler) notifyChange(eventType string, bookID primitive.ObjectID, before, after *Book, at time.Time) {
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	h.webhooks.notify(newWebhookEvent(eventType, bookID, before, after, at))
+}
+
+// webhookNotifier delivers events to each URL in the background, retrying
+// failures with backoff. An event already delivered to a URL, or being
+// delivered, is not sent there again within the dedup window. A nil notifier
+// drops every event.
+type webhookNotifier struct {
+	urls        []string
+	window      time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	client      *http.Client
+	now         func() time.Time
+
+	mu        sync.Mutex
+	delivered map[string]time.Time // event ID + URL -> time of success
+	inFlight  map[string]bool
+}
+
+func newWebhookNotifier(cfg Config) *webhookNotifier {
+	if len(cfg.WebhookURLs) == 0 {
+		return nil
+	}
+	return &webhookNotifier{
+		urls:        cfg.WebhookURLs,
+		window:      cfg.WebhookDedupWindow,
+		maxAttempts: cfg.WebhookMaxAttempts,
+		backoff:     time.Second,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		delivered:   make(map[string]time.Time),
+		inFlight:    make(map[string]bool),
+	}
+}
+
+func (n *webhookNotifier) notify(event WebhookEvent) {
+	if n == nil {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("webhook %s: encode: %v", event.ID, err)
+		return
+	}
+	for _, url := range n.urls {
+		key := event.ID + " " + url
+		if !n.claim(key) {
+			continue
+		}
+		go n.deliver(key, url, event.ID, body)
+	}
+}
+
+// claim reserves key for delivery unless it was delivered within the window
+// or is already in flight. It also forgets deliveries older than the window.
+func (n *webhookNotifier) claim(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	for k, at := range n.delivered {
+		if now.Sub(at) >= n.window {
+			delete(n.delivered, k)
+		}
+	}
+	if _, ok := n.delivered[key]; ok || n.inFlight[key] {
+		return false
+	}
+	n.inFlight[key] = true
+	return true
+}
+
+// finish releases a claim, remembering it only if delivery succeeded so that
+// a failed event can still be sent again.
+func (n *webhookNotifier) finish(key string, ok bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.inFlight, key)
+	if ok {
+		n.delivered[key] = n.now()
+	}
+}
+
+func (n *webhookNotifier) deliver(key, url, eventID string, body []byte) {
+	delay := n.backoff
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		err := n.post(url, eventID, body)
+		if err == nil {
+			n.finish(key, true)
+			return
+		}
+		log.Printf("webhook %s to %s: attempt %d/%d: %v", eventID, url, attempt, n.maxAttempts, err)
+		if attempt < n.maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	n.finish(key, false)
+}
+
+func (n *webhookNotifier) post(url, eventID string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", eventID)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
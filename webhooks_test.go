@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// webhookReceiver records the X-Event-ID of every delivery, answering 500
+// to the first failures of them.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	ids      []string
+	failures int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, req.Header.Get("X-Event-ID"))
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// waitFor polls until the receiver has seen n deliveries.
+func (r *webhookReceiver) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		ids := append([]string(nil), r.ids...)
+		r.mu.Unlock()
+		if len(ids) >= n {
+			return ids
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d deliveries", n)
+	return nil
+}
+
+func newTestNotifier(t *testing.T, receiver *webhookReceiver) *webhookNotifier {
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	cfg := testConfig()
+	cfg.WebhookURLs = []string{server.URL}
+	cfg.WebhookMaxAttempts = 3
+	n := newWebhookNotifier(cfg)
+	n.backoff = time.Millisecond
+	return n
+}
+
+func TestWebhookRepeatedStatesAreSeparateEvents(t *testing.T) {
+	receiver := &webhookReceiver{}
+	n := newTestNotifier(t, receiver)
+	id := primitive.NewObjectID()
+	a := Book{ID: id, Title: "Dune", Price: 9.99}
+	b := Book{ID: id, Title: "Dune", Price: 7.99}
+
+	// A -> B -> A: the second write restores the first state but is still
+	// a change receivers must hear about.
+	n.notify(newWebhookEvent(eventBookUpdated, id, &a, &b, time.Now()))
+	receiver.waitFor(t, 1)
+	n.notify(newWebhookEvent(eventBookUpdated, id, &b, &a, time.Now()))
+	ids := receiver.waitFor(t, 2)
+	if ids[0] == ids[1] {
+		t.Fatalf("A -> B -> A reused event ID %s", ids[0])
+	}
+
+	// The same change built twice, as two racing retries of one write would,
+	// is delivered once.
+	n.notify(newWebhookEvent(eventBookUpdated, id, &b, &a, time.Now()))
+	time.Sleep(50 * time.Millisecond)
+	if got := len(receiver.waitFor(t, 2)); got != 2 {
+		t.Fatalf("duplicate event delivered again: %d deliveries", got)
+	}
+}
+
+func TestWebhookEventIDIgnoresMapOrder(t *testing.T) {
+	id := primitive.NewObjectID()
+	stock := map[string]int{}
+	for i := 0; i < 20; i++ {
+		stock[fmt.Sprint("branch-", i)] = i
+	}
+	before := Book{ID: id, Title: "Dune"}
+	after := Book{ID: id, Title: "Dune", StockByLocation: stock}
+	want := newWebhookEvent(eventBookUpdated, id, &before, &after, time.Now()).ID
+	for i := 0; i < 10; i++ {
+		if got := newWebhookEvent(eventBookUpdated, id, &before, &after, time.Now()).ID; got != want {
+			t.Fatalf("event ID = %s, then %s for the same change", want, got)
+		}
+	}
+}
+
+func TestWebhookRetriedPutIsDeliveredOnce(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	h, router := newTestHandler(t, func(cfg *Config) { cfg.WebhookURLs = []string{server.URL} })
+	ids := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert", Price: 9.99})
+	path := "/books/" + ids[0].Hex()
+	body := `{"title": "Dune", "author": "Frank Herbert", "price": 7.99}`
+
+	expectStatus(t, serve(router, http.MethodPut, path, body), http.StatusOK)
+	receiver.waitFor(t, 1)
+	// The client never saw the first response and sends the PUT again.
+	expectStatus(t, serve(router, http.MethodPut, path, body), http.StatusOK)
+	time.Sleep(50 * time.Millisecond)
+	if got := receiver.waitFor(t, 1); len(got) != 1 {
+		t.Fatalf("retried PUT delivered %d events: %v", len(got), got)
+	}
+}
+
+func TestWebhookRetriesKeepTheEventID(t *testing.T) {
+	receiver := &webhookReceiver{failures: 2}
+	n := newTestNotifier(t, receiver)
+	id := primitive.NewObjectID()
+	event := newWebhookEvent(eventBookCreated, id, nil, &Book{ID: id, Title: "Dune"}, time.Now())
+
+	n.notify(event)
+	ids := receiver.waitFor(t, 3)
+	for _, got := range ids {
+		if got != event.ID {
+			t.Fatalf("delivery IDs = %v, want %s each time", ids, event.ID)
+		}
+	}
+}
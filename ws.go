@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// wsBatchWindow is how long changes are collected after the first one
+	// before they go out as a single message.
+	wsBatchWindow = 250 * time.Millisecond
+	// wsPingEvery must stay below wsPongWait so a healthy client always
+	// answers in time.
+	wsPingEvery = 30 * time.Second
+	wsPongWait  = 45 * time.Second
+	wsWriteWait = 10 * time.Second
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// bookChange is one entry in a batched WebSocket message.
+type bookChange struct {
+	Operation string             `json:"operation"`
+	ID        primitive.ObjectID `json:"id"`
+}
+
+// Upgrade to a WebSocket and push batched change notifications from the
+// collection's change stream. Requires a replica set.
+func (h *BookHandler) watchBooksWS(c *gin.Context) {
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // the upgrader has already answered
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	stream, err := h.collection.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		log.Printf("ws: %v", err)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "change stream unavailable"),
+			time.Now().Add(wsWriteWait))
+		return
+	}
+	defer stream.Close(context.Background())
+
+	// The read loop only exists to process pongs and notice the client
+	// going away; clients are not expected to send anything.
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	changes := make(chan bookChange)
+	go func() {
+		defer cancel()
+		for stream.Next(ctx) {
+			var event struct {
+				OperationType string `bson:"operationType"`
+				DocumentKey   struct {
+					ID primitive.ObjectID `bson:"_id"`
+				} `bson:"documentKey"`
+			}
+			if err := stream.Decode(&event); err != nil {
+				continue
+			}
+			select {
+			case changes <- bookChange{Operation: event.OperationType, ID: event.DocumentKey.ID}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingEvery)
+	defer ping.Stop()
+	var (
+		batch []bookChange
+		flush <-chan time.Time
+	)
+	for {
+		select {
+		case change := <-changes:
+			if batch == nil {
+				flush = time.After(wsBatchWindow)
+			}
+			batch = append(batch, change)
+		case <-flush:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(gin.H{"changes": batch}); err != nil {
+				return
+			}
+			batch, flush = nil, nil
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(wsWriteWait))
+			return
+		}
+	}
+}
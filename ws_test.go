@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWebSocketBatchesRapidChanges(t *testing.T) {
+	h, router := newTestHandler(t)
+	requireReplicaSet(t)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/books/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	type message struct {
+		Changes []bookChange `json:"changes"`
+	}
+	messages := make(chan message)
+	go func() {
+		defer close(messages)
+		for {
+			var m message
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			messages <- m
+		}
+	}()
+
+	// The change stream opens after the upgrade, so probe until it reports
+	// something, then let any stragglers go out before the real changes.
+	ctx := testContext(t)
+	for started := false; !started; {
+		insertBooks(t, h, Book{Title: "Probe", Author: "A"})
+		select {
+		case _, ok := <-messages:
+			if !ok {
+				t.Fatal("connection closed before the change stream reported anything")
+			}
+			started = true
+		case <-time.After(300 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("change stream never reported the probe")
+		}
+	}
+	for quiet := false; !quiet; {
+		select {
+		case <-messages:
+		case <-time.After(2 * wsBatchWindow):
+			quiet = true
+		}
+	}
+
+	id := insertBooks(t, h, Book{Title: "Dune", Author: "Frank Herbert"})[0]
+	if _, err := h.collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"price": 12}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.collection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-messages:
+		want := []bookChange{{"insert", id}, {"update", id}, {"delete", id}}
+		if !reflect.DeepEqual(m.Changes, want) {
+			t.Fatalf("batch = %v, want %v", m.Changes, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batched message")
+	}
+}